@@ -0,0 +1,238 @@
+package jsonrpc
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LimitAlgorithm defines how an AdaptiveLimiter adjusts its concurrency limit.
+type LimitAlgorithm int
+
+const (
+	// AlgorithmAIMD increases the limit by one if a call succeeds while the limit is used
+	// and multiplies it with BackoffRatio if latency rises above the tolerance or an overload error occurs.
+	AlgorithmAIMD LimitAlgorithm = iota
+	// AlgorithmGradient continuously scales the limit by the ratio of the long term latency to the current latency.
+	AlgorithmGradient
+)
+
+// AdaptiveLimiterOpts can be provided to NewAdaptiveLimiter() to change the configuration of an AdaptiveLimiter.
+//
+// Algorithm: the algorithm that adjusts the limit, defaults to AlgorithmAIMD.
+//
+// InitialLimit: limit at start, defaults to 10. MinLimit: defaults to 1. MaxLimit: defaults to 1000.
+//
+// BackoffRatio: factor the limit is multiplied with on overload, defaults to 0.9.
+//
+// LatencyTolerance: a latency is considered rising if it exceeds LatencyTolerance times the long term latency, defaults to 2.
+//
+// Smoothing: weight of a new limit calculated by AlgorithmGradient, defaults to 0.2.
+type AdaptiveLimiterOpts struct {
+	Algorithm        LimitAlgorithm
+	InitialLimit     int
+	MinLimit         int
+	MaxLimit         int
+	BackoffRatio     float64
+	LatencyTolerance float64
+	Smoothing        float64
+}
+
+// AdaptiveLimiter limits the number of concurrent in-flight requests to a limit that adapts to the observed latency.
+//
+// The limit grows while latency is stable and is cut when latency rises or overload errors occur
+// (HTTP status 429, 502, 503, 504 or timeouts). Calls that exceed the limit are queued like in a Bulkhead.
+//
+// An AdaptiveLimiter is provided with RPCClientOpts.AdaptiveLimiter and may be shared by multiple clients.
+// It is created using the factory function NewAdaptiveLimiter().
+type AdaptiveLimiter struct {
+	algorithm        LimitAlgorithm
+	minLimit         float64
+	maxLimit         float64
+	backoffRatio     float64
+	latencyTolerance float64
+	smoothing        float64
+
+	mu          sync.Mutex
+	limit       float64
+	inFlight    int
+	longLatency time.Duration
+	queue       waiterQueue
+	seq         uint64
+}
+
+// NewAdaptiveLimiter returns a new AdaptiveLimiter.
+//
+// opts: AdaptiveLimiterOpts is used to provide custom configuration, can be nil.
+func NewAdaptiveLimiter(opts *AdaptiveLimiterOpts) *AdaptiveLimiter {
+	if opts == nil {
+		opts = &AdaptiveLimiterOpts{}
+	}
+
+	limiter := &AdaptiveLimiter{
+		algorithm:        opts.Algorithm,
+		minLimit:         float64(opts.MinLimit),
+		maxLimit:         float64(opts.MaxLimit),
+		backoffRatio:     opts.BackoffRatio,
+		latencyTolerance: opts.LatencyTolerance,
+		smoothing:        opts.Smoothing,
+		limit:            float64(opts.InitialLimit),
+	}
+
+	if limiter.minLimit < 1 {
+		limiter.minLimit = 1
+	}
+	if limiter.maxLimit < limiter.minLimit {
+		limiter.maxLimit = math.Max(1000, limiter.minLimit)
+	}
+	if limiter.backoffRatio <= 0 || limiter.backoffRatio >= 1 {
+		limiter.backoffRatio = 0.9
+	}
+	if limiter.latencyTolerance < 1 {
+		limiter.latencyTolerance = 2
+	}
+	if limiter.smoothing <= 0 || limiter.smoothing > 1 {
+		limiter.smoothing = 0.2
+	}
+	if limiter.limit <= 0 {
+		limiter.limit = 10
+	}
+	limiter.limit = math.Min(limiter.maxLimit, math.Max(limiter.minLimit, limiter.limit))
+
+	return limiter
+}
+
+// Limit returns the current concurrency limit.
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int(l.limit)
+}
+
+// InFlight returns the number of calls currently in flight.
+func (l *AdaptiveLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.inFlight
+}
+
+// acquire waits until the call is allowed by the current limit.
+// The returned function must be called with the result of the call.
+func (l *AdaptiveLimiter) acquire(ctx context.Context) (func(err error), error) {
+	if l == nil {
+		return func(error) {}, nil
+	}
+
+	l.mu.Lock()
+	if l.inFlight < int(l.limit) && len(l.queue) == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return l.releaser(time.Now()), nil
+	}
+
+	l.seq++
+	w := &waiter{
+		priority: priorityFrom(ctx),
+		seq:      l.seq,
+		ready:    make(chan struct{}),
+	}
+	heap.Push(&l.queue, w)
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+	case <-ctx.Done():
+		l.mu.Lock()
+		if w.index >= 0 {
+			heap.Remove(&l.queue, w.index)
+			l.mu.Unlock()
+			return nil, ctx.Err()
+		}
+		// the slot was granted concurrently, so the call may still proceed
+		l.mu.Unlock()
+	}
+
+	return l.releaser(time.Now()), nil
+}
+
+func (l *AdaptiveLimiter) releaser(start time.Time) func(err error) {
+	return func(err error) {
+		l.release(time.Since(start), err)
+	}
+}
+
+// release adjusts the limit with the result of a call and admits waiting calls.
+func (l *AdaptiveLimiter) release(latency time.Duration, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	inFlight := l.inFlight
+	l.inFlight--
+
+	overload := isOverload(err)
+	if err == nil || overload {
+		l.update(latency, inFlight, overload)
+	}
+
+	for len(l.queue) > 0 && l.inFlight < int(l.limit) {
+		w := heap.Pop(&l.queue).(*waiter)
+		l.inFlight++
+		close(w.ready)
+	}
+}
+
+// update calculates the new limit, must be called with l.mu held.
+func (l *AdaptiveLimiter) update(latency time.Duration, inFlight int, overload bool) {
+	if l.longLatency == 0 {
+		l.longLatency = latency
+	}
+	rising := float64(latency) > l.latencyTolerance*float64(l.longLatency)
+
+	switch {
+	case overload:
+		l.limit *= l.backoffRatio
+	case l.algorithm == AlgorithmGradient:
+		gradient := math.Max(0.5, math.Min(1, l.latencyTolerance*float64(l.longLatency)/float64(latency)))
+		newLimit := l.limit*gradient + math.Sqrt(l.limit)
+		l.limit = l.limit*(1-l.smoothing) + newLimit*l.smoothing
+	case rising:
+		l.limit *= l.backoffRatio
+	case inFlight*2 >= int(l.limit):
+		// only grow if the limit is actually used
+		l.limit++
+	}
+
+	l.limit = math.Min(l.maxLimit, math.Max(l.minLimit, l.limit))
+
+	// the long term latency follows slowly so that a rising latency can be detected
+	if !overload {
+		l.longLatency += (latency - l.longLatency) / 20
+	}
+}
+
+// isOverload returns true if err signals that the backend is overloaded.
+func isOverload(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.Code {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,122 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveLimiter(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("defaults should be applied", func(t *testing.T) {
+		limiter := NewAdaptiveLimiter(nil)
+		check.Equal(10, limiter.Limit())
+		check.Equal(0, limiter.InFlight())
+	})
+
+	t.Run("aimd should grow while latency is stable", func(t *testing.T) {
+		limiter := NewAdaptiveLimiter(&AdaptiveLimiterOpts{InitialLimit: 2, MaxLimit: 5})
+
+		for i := 0; i < 10; i++ {
+			// the limit is only increased if it is actually used
+			n := limiter.Limit()
+			for j := 0; j < n; j++ {
+				_, err := limiter.acquire(context.Background())
+				check.Nil(err)
+			}
+			for j := 0; j < n; j++ {
+				limiter.release(10*time.Millisecond, nil)
+			}
+		}
+		check.Equal(5, limiter.Limit())
+	})
+
+	t.Run("aimd should cut the limit on rising latency", func(t *testing.T) {
+		limiter := NewAdaptiveLimiter(&AdaptiveLimiterOpts{InitialLimit: 100, BackoffRatio: 0.5})
+
+		limiter.acquire(context.Background())
+		limiter.release(10*time.Millisecond, nil)
+		check.Equal(100, limiter.Limit())
+
+		limiter.acquire(context.Background())
+		limiter.release(100*time.Millisecond, nil)
+		check.Equal(50, limiter.Limit())
+	})
+
+	t.Run("gradient should follow the latency", func(t *testing.T) {
+		limiter := NewAdaptiveLimiter(&AdaptiveLimiterOpts{Algorithm: AlgorithmGradient, InitialLimit: 16, LatencyTolerance: 1})
+
+		for i := 0; i < 5; i++ {
+			limiter.acquire(context.Background())
+			limiter.release(10*time.Millisecond, nil)
+		}
+		grown := limiter.Limit()
+		check.Greater(grown, 16)
+
+		for i := 0; i < 5; i++ {
+			limiter.acquire(context.Background())
+			limiter.release(100*time.Millisecond, nil)
+		}
+		check.Less(limiter.Limit(), grown)
+	})
+
+	t.Run("overload errors should cut the limit", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer s.Close()
+
+		limiter := NewAdaptiveLimiter(&AdaptiveLimiterOpts{InitialLimit: 20, BackoffRatio: 0.5})
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{AdaptiveLimiter: limiter})
+
+		_, err := rpcClient.Call(context.Background(), "something")
+		check.NotNil(err)
+		check.Equal(10, limiter.Limit())
+
+		_, err = rpcClient.CallBatch(context.Background(), RPCRequests{NewRequest("something")})
+		check.NotNil(err)
+		check.Equal(5, limiter.Limit())
+	})
+
+	t.Run("client should not exceed the limit", func(t *testing.T) {
+		var mu sync.Mutex
+		current, max := 0, 0
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			current++
+			if current > max {
+				max = current
+			}
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			current--
+			mu.Unlock()
+			w.Write([]byte(`{"result":1}`))
+		}))
+		defer s.Close()
+
+		limiter := NewAdaptiveLimiter(&AdaptiveLimiterOpts{InitialLimit: 2, MaxLimit: 2})
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{AdaptiveLimiter: limiter})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 6; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := rpcClient.Call(context.Background(), "something")
+				check.Nil(err)
+			}()
+		}
+		wg.Wait()
+
+		check.LessOrEqual(max, 2)
+		check.Equal(0, limiter.InFlight())
+	})
+}
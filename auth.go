@@ -0,0 +1,182 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// AuthProvider authenticates the http requests of a client (see RPCClientOpts.Auth).
+//
+// Implementations must be safe for concurrent use.
+type AuthProvider interface {
+	// Authenticate is called for every http request after the body was encoded and all other headers were set,
+	// including every request of a retry or hedge. body must not be modified.
+	// If an error is returned, the call fails without sending a request.
+	Authenticate(ctx context.Context, request *http.Request, body []byte) error
+}
+
+// AuthRefresher can be implemented by an AuthProvider whose credentials can be renewed.
+//
+// If the server responds with 401 Unauthorized, Refresh is called with the response (its body is not read yet)
+// and the request is authenticated and sent once more. If Refresh returns an error, the call fails with an HTTPError
+// of the rejected response that wraps it.
+type AuthRefresher interface {
+	Refresh(ctx context.Context, response *http.Response) error
+}
+
+// ChainAuth returns an AuthProvider that applies the given providers in order, e.g. a bearer token
+// together with a request signature:
+//
+//	Auth: jsonrpc.ChainAuth(
+//		jsonrpc.NewClientCredentials(tokenURL, clientID, clientSecret, nil),
+//		jsonrpc.NewHMACSigner(secret, nil), // last, so that the signature covers the Authorization header
+//	)
+//
+// Every provider sees the headers set by the previous ones. If one of them returns an error, the call fails with it.
+//
+// The returned AuthProvider implements AuthRefresher if one of the providers does. Refresh is then forwarded to all
+// providers that implement it and fails if one of them fails.
+func ChainAuth(providers ...AuthProvider) AuthProvider {
+	chain := authChain(providers)
+	for _, provider := range providers {
+		if _, ok := provider.(AuthRefresher); ok {
+			return refreshingAuthChain{chain}
+		}
+	}
+
+	return chain
+}
+
+type authChain []AuthProvider
+
+func (c authChain) Authenticate(ctx context.Context, request *http.Request, body []byte) error {
+	for _, provider := range c {
+		if err := provider.Authenticate(ctx, request, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshingAuthChain is an authChain with at least one AuthRefresher.
+type refreshingAuthChain struct {
+	authChain
+}
+
+func (c refreshingAuthChain) Refresh(ctx context.Context, response *http.Response) error {
+	var errs []error
+	for _, provider := range c.authChain {
+		if refresher, ok := provider.(AuthRefresher); ok {
+			if err := refresher.Refresh(ctx, response); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// endpointAuthorization returns the basic Authorization header for the credentials of the endpoint url,
+// empty if it has none.
+func endpointAuthorization(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.User == nil {
+		return ""
+	}
+
+	password, _ := u.User.Password()
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(u.User.Username()+":"+password))
+}
+
+// withoutUserinfo returns a shallow copy of the request without the credentials of its url.
+// They are sent as basic Authorization header (see endpointAuthorization()), unless the AuthProvider removes it,
+// so the http client must not add them on its own.
+func withoutUserinfo(request *http.Request) *http.Request {
+	if request.URL.User == nil {
+		return request
+	}
+
+	u := *request.URL
+	u.User = nil
+	stripped := request.WithContext(request.Context())
+	stripped.URL = &u
+	return stripped
+}
+
+// authenticate applies the AuthProvider of the client to a request, if there is one.
+func (client *rpcClient) authenticate(ctx context.Context, request *http.Request, body []byte) error {
+	if client.auth == nil {
+		return nil
+	}
+
+	if err := client.auth.Authenticate(ctx, request, body); err != nil {
+		return fmt.Errorf("authenticate request: %w", err)
+	}
+	return nil
+}
+
+// do sends the http request of ex. If the server rejects the credentials with 401 Unauthorized and the
+// AuthProvider can refresh them, the request is authenticated again and sent once more.
+func (client *rpcClient) do(ctx context.Context, requests []*RPCRequest, batch bool, ex *exchange) (*http.Response, error) {
+	client.hooks.request(ctx, requests, batch, ex)
+
+	httpResponse, err := client.httpClient.Do(ex.traceTiming(withoutUserinfo(ex.request)))
+	if err != nil {
+		return nil, &classifiedError{ErrorClassNetwork, err}
+	}
+	if httpResponse.StatusCode != http.StatusUnauthorized {
+		return httpResponse, nil
+	}
+
+	refresher, ok := client.auth.(AuthRefresher)
+	if !ok {
+		return httpResponse, nil
+	}
+
+	err = refresher.Refresh(ctx, httpResponse)
+	snippet, _ := io.ReadAll(io.LimitReader(httpResponse.Body, maxHTTPErrorBody))
+	io.Copy(io.Discard, httpResponse.Body)
+	httpResponse.Body.Close()
+	if err != nil {
+		return nil, &HTTPError{
+			Code:      httpResponse.StatusCode,
+			RateLimit: parseRateLimitInfo(httpResponse, time.Now()),
+			Header:    httpResponse.Header,
+			Body:      snippet,
+			err:       fmt.Errorf("request to %v rejected with status code: %v. refresh credentials: %w", ex.request.URL.Redacted(), httpResponse.StatusCode, err),
+		}
+	}
+
+	retry := ex.request.Clone(ctx)
+	retry.Body = io.NopCloser(bytes.NewReader(ex.requestBody))
+	if err := client.authenticate(ctx, retry, ex.requestBody); err != nil {
+		return nil, err
+	}
+
+	method := ""
+	if !batch {
+		method = requests[0].Method
+	}
+	client.hooks.retry(ctx, method, 1, retry.URL.Redacted(), &HTTPError{
+		Code:   httpResponse.StatusCode,
+		Header: httpResponse.Header,
+		Body:   snippet,
+		err:    fmt.Errorf("request to %v rejected with status code: %v", ex.request.URL.Redacted(), httpResponse.StatusCode),
+	})
+
+	ex.request = retry
+	client.hooks.request(ctx, requests, batch, ex)
+
+	// the timing of the rejected request is replaced, it does not include the refresh of the credentials
+	httpResponse, err = client.httpClient.Do(ex.traceTiming(withoutUserinfo(retry)))
+	if err != nil {
+		return nil, &classifiedError{ErrorClassNetwork, err}
+	}
+	return httpResponse, nil
+}
@@ -0,0 +1,229 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// AutoBatchOpts can be provided to NewAutoBatchClient() to change the configuration of the auto batching.
+//
+// Window: time calls are collected before they are sent as one batch, defaults to 2ms.
+//
+// MaxBatchSize: a batch is sent immediately if it contains this number of calls, defaults to 100.
+type AutoBatchOpts struct {
+	Window       time.Duration
+	MaxBatchSize int
+}
+
+// AutoBatchClient is an RPCClient that transparently collects calls made within a short time window
+// and sends them as one batch request. Every caller receives its own RPCResponse or error.
+//
+// Call(), CallRaw() and CallFor() are batched. CallBatch() and CallBatchRaw() are passed to the underlying client.
+// A batch with a single call is sent as a normal request.
+//
+// Only calls made with the same context are sent in the same batch, and the batch request is made with that context,
+// since the HeaderProvider, AuthProvider, hooks and other parts of the client may read any value of the context.
+// Calls that should share a batch must therefore use the same context, e.g. the context of an incoming request.
+//
+// AutoBatchClient is created using the factory function NewAutoBatchClient().
+type AutoBatchClient struct {
+	client       RPCClient
+	window       time.Duration
+	maxBatchSize int
+
+	mu      sync.Mutex
+	pending map[context.Context]*pendingBatch
+}
+
+// pendingBatch collects the calls of a batch until its window is over or it is full.
+type pendingBatch struct {
+	calls []*batchedCall
+	timer *time.Timer
+}
+
+type batchedCall struct {
+	ctx     context.Context
+	request *RPCRequest
+	done    chan batchedResult
+}
+
+type batchedResult struct {
+	res *RPCResponse
+	err error
+}
+
+// NewAutoBatchClient returns a new AutoBatchClient that sends its batches with client.
+//
+// opts: AutoBatchOpts is used to provide custom configuration, can be nil.
+func NewAutoBatchClient(client RPCClient, opts *AutoBatchOpts) *AutoBatchClient {
+	if opts == nil {
+		opts = &AutoBatchOpts{}
+	}
+
+	autoBatchClient := &AutoBatchClient{
+		client:       client,
+		window:       opts.Window,
+		maxBatchSize: opts.MaxBatchSize,
+		pending:      make(map[context.Context]*pendingBatch),
+	}
+
+	if autoBatchClient.window <= 0 {
+		autoBatchClient.window = 2 * time.Millisecond
+	}
+	if autoBatchClient.maxBatchSize < 1 {
+		autoBatchClient.maxBatchSize = 100
+	}
+
+	return autoBatchClient
+}
+
+// Call adds a JSON-RPC request to the next batch and waits for its response. See RPCClient.Call().
+func (a *AutoBatchClient) Call(ctx context.Context, method string, params ...interface{}) (*RPCResponse, error) {
+	return a.CallRaw(ctx, NewRequest(method, params...))
+}
+
+// CallRaw adds the given RPCRequest to the next batch and waits for its response. See RPCClient.CallRaw().
+//
+// The id of the request is replaced in the batch, but restored in the returned RPCResponse.
+func (a *AutoBatchClient) CallRaw(ctx context.Context, request *RPCRequest) (*RPCResponse, error) {
+	call := &batchedCall{
+		ctx:     ctx,
+		request: request,
+		done:    make(chan batchedResult, 1),
+	}
+
+	a.enqueue(call)
+
+	select {
+	case result := <-call.done:
+		return result.res, result.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("rpc call %v(): %w", request.Method, ctx.Err())
+	}
+}
+
+// CallFor adds a JSON-RPC request to the next batch and stores the result in out. See RPCClient.CallFor().
+func (a *AutoBatchClient) CallFor(ctx context.Context, out interface{}, method string, params ...interface{}) error {
+	rpcResponse, err := a.Call(ctx, method, params...)
+	if err != nil {
+		return err
+	}
+
+	if rpcResponse.Error != nil {
+		return rpcResponse.Error
+	}
+
+	return rpcResponse.GetObject(out)
+}
+
+// CallBatch is passed to the underlying client. See RPCClient.CallBatch().
+func (a *AutoBatchClient) CallBatch(ctx context.Context, requests RPCRequests) (RPCResponses, error) {
+	return a.client.CallBatch(ctx, requests)
+}
+
+// CallBatchRaw is passed to the underlying client. See RPCClient.CallBatchRaw().
+func (a *AutoBatchClient) CallBatchRaw(ctx context.Context, requests RPCRequests) (RPCResponses, error) {
+	return a.client.CallBatchRaw(ctx, requests)
+}
+
+// Flush sends all collected calls immediately.
+func (a *AutoBatchClient) Flush() {
+	a.mu.Lock()
+	batches := make([]*pendingBatch, 0, len(a.pending))
+	for key, batch := range a.pending {
+		a.takePending(key, batch)
+		batches = append(batches, batch)
+	}
+	a.mu.Unlock()
+
+	for _, batch := range batches {
+		go a.send(batch.calls)
+	}
+}
+
+func (a *AutoBatchClient) enqueue(call *batchedCall) {
+	// contexts that cannot be used as map key are never batched
+	if !reflect.TypeOf(call.ctx).Comparable() {
+		go a.send([]*batchedCall{call})
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := call.ctx
+	batch, ok := a.pending[key]
+	if !ok {
+		batch = &pendingBatch{}
+		batch.timer = time.AfterFunc(a.window, func() { a.flush(key, batch) })
+		a.pending[key] = batch
+	}
+
+	batch.calls = append(batch.calls, call)
+
+	if len(batch.calls) >= a.maxBatchSize {
+		a.takePending(key, batch)
+		go a.send(batch.calls)
+	}
+}
+
+// flush sends the batch when its window is over, unless it was already sent.
+func (a *AutoBatchClient) flush(key context.Context, batch *pendingBatch) {
+	a.mu.Lock()
+	if a.pending[key] != batch {
+		a.mu.Unlock()
+		return
+	}
+	a.takePending(key, batch)
+	a.mu.Unlock()
+
+	a.send(batch.calls)
+}
+
+// takePending removes the batch from the pending ones and stops its window, must be called with a.mu held.
+func (a *AutoBatchClient) takePending(key context.Context, batch *pendingBatch) {
+	delete(a.pending, key)
+	batch.timer.Stop()
+}
+
+// send sends the calls as one batch and routes the responses back to their callers.
+func (a *AutoBatchClient) send(calls []*batchedCall) {
+	// all calls of a batch share their context
+	ctx := calls[0].ctx
+
+	if len(calls) == 1 {
+		res, err := a.client.CallRaw(ctx, calls[0].request)
+		calls[0].done <- batchedResult{res, err}
+		return
+	}
+
+	requests := make(RPCRequests, len(calls))
+	for i, call := range calls {
+		request := *call.request
+		request.ID = i
+		request.JSONRPC = jsonrpcVersion
+		requests[i] = &request
+	}
+
+	responses, err := a.client.CallBatchRaw(ctx, requests)
+	byID := responses.AsMap()
+
+	for i, call := range calls {
+		res, ok := byID[i]
+		if !ok {
+			if err != nil {
+				call.done <- batchedResult{nil, err}
+			} else {
+				call.done <- batchedResult{nil, fmt.Errorf("rpc call %v(): batch response is missing id %v", call.request.Method, i)}
+			}
+			continue
+		}
+
+		restored := *res
+		restored.ID = call.request.ID
+		call.done <- batchedResult{&restored, err}
+	}
+}
@@ -0,0 +1,212 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// echo answers every request with its first param as result and records the batch sizes
+func echo(sizes chan int) testHandler {
+	return func(w http.ResponseWriter, r *http.Request, body []byte) {
+		type request struct {
+			ID     int           `json:"id"`
+			Params []interface{} `json:"params"`
+		}
+		answer := func(req request) map[string]interface{} {
+			return map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": req.Params[0]}
+		}
+
+		if body[0] != '[' {
+			var req request
+			json.Unmarshal(body, &req)
+			sizes <- 1
+			json.NewEncoder(w).Encode(answer(req))
+			return
+		}
+
+		var requests []request
+		json.Unmarshal(body, &requests)
+		sizes <- len(requests)
+
+		// answer in reverse order to check that responses are mapped by id
+		var responses []map[string]interface{}
+		for i := len(requests) - 1; i >= 0; i-- {
+			responses = append(responses, answer(requests[i]))
+		}
+		json.NewEncoder(w).Encode(responses)
+	}
+}
+
+func TestAutoBatchClient(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("concurrent calls should be sent as one batch", func(t *testing.T) {
+		sizes := make(chan int, 10)
+		s := newTestServer(t, echo(sizes))
+		client := NewAutoBatchClient(NewClient(s.URL), &AutoBatchOpts{Window: 50 * time.Millisecond})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				var out int
+				err := client.CallFor(context.Background(), &out, "echo", i)
+				check.Nil(err)
+				check.Equal(i, out)
+			}(i)
+		}
+		wg.Wait()
+
+		check.Equal(5, <-sizes)
+		check.Len(sizes, 0)
+	})
+
+	t.Run("full batch should be sent immediately", func(t *testing.T) {
+		sizes := make(chan int, 10)
+		s := newTestServer(t, echo(sizes))
+		client := NewAutoBatchClient(NewClient(s.URL), &AutoBatchOpts{Window: time.Hour, MaxBatchSize: 2})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				res, err := client.Call(context.Background(), "echo", i)
+				check.Nil(err)
+				check.Equal(json.Number(strconv.Itoa(i)), res.Result)
+			}(i)
+		}
+		wg.Wait()
+
+		check.Equal(2, <-sizes)
+		check.Equal(2, <-sizes)
+	})
+
+	t.Run("single call should be sent as normal request and keep its id", func(t *testing.T) {
+		sizes := make(chan int, 10)
+		s := newTestServer(t, echo(sizes))
+		client := NewAutoBatchClient(NewClient(s.URL), nil)
+
+		res, err := client.CallRaw(context.Background(), NewRequestWithID(42, "echo", "a"))
+		check.Nil(err)
+		check.Equal("a", res.Result)
+		check.Equal(42, res.ID)
+		check.Equal(1, <-sizes)
+	})
+
+	t.Run("batch ids should be restored", func(t *testing.T) {
+		sizes := make(chan int, 10)
+		s := newTestServer(t, echo(sizes))
+		client := NewAutoBatchClient(NewClient(s.URL), &AutoBatchOpts{Window: time.Hour, MaxBatchSize: 2})
+
+		var wg sync.WaitGroup
+		for _, id := range []int{7, 8} {
+			wg.Add(1)
+			go func(id int) {
+				defer wg.Done()
+				res, err := client.CallRaw(context.Background(), NewRequestWithID(id, "echo", id))
+				check.Nil(err)
+				check.Equal(id, res.ID)
+			}(id)
+		}
+		wg.Wait()
+	})
+
+	t.Run("canceled caller should return immediately", func(t *testing.T) {
+		sizes := make(chan int, 10)
+		s := newTestServer(t, echo(sizes))
+		client := NewAutoBatchClient(NewClient(s.URL), &AutoBatchOpts{Window: time.Hour})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := client.Call(ctx, "echo", 1)
+		check.ErrorIs(err, context.DeadlineExceeded)
+
+		client.Flush()
+	})
+
+	t.Run("calls with different contexts should not share a batch", func(t *testing.T) {
+		sizes := make(chan int, 10)
+		s := newTestServer(t, echo(sizes))
+		client := NewAutoBatchClient(NewClient(s.URL), &AutoBatchOpts{Window: 50 * time.Millisecond})
+
+		contexts := map[string]context.Context{
+			"a": WithHeaders(context.Background(), map[string]string{"X-Tenant": "a"}),
+			"b": WithHeaders(context.Background(), map[string]string{"X-Tenant": "b"}),
+		}
+
+		var wg sync.WaitGroup
+		for i, tenant := range []string{"a", "b", "a"} {
+			wg.Add(1)
+			go func(i int, tenant string) {
+				defer wg.Done()
+				var out int
+				check.Nil(client.CallFor(contexts[tenant], &out, "echo", i))
+				check.Equal(i, out)
+			}(i, tenant)
+		}
+		wg.Wait()
+
+		check.ElementsMatch([]int{1, 2}, []int{<-sizes, <-sizes})
+	})
+
+	t.Run("header provider should see the context of every call", func(t *testing.T) {
+		// calls that were batched together would get the tenant of the first call
+		s := newTestServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+			fmt.Fprintf(w, `{"result":%q}`, r.Header.Get("X-Tenant"))
+		})
+		client := NewAutoBatchClient(NewClientWithOpts(s.URL, &RPCClientOpts{
+			HeaderProvider: func(ctx context.Context) (map[string]string, error) {
+				tenant, _ := ctx.Value(tenantKey{}).(string)
+				return map[string]string{"X-Tenant": tenant}, nil
+			},
+		}), &AutoBatchOpts{Window: 20 * time.Millisecond})
+
+		var wg sync.WaitGroup
+		for _, tenant := range []string{"a", "b", "a", "b"} {
+			wg.Add(1)
+			go func(tenant string) {
+				defer wg.Done()
+				var out string
+				check.Nil(client.CallFor(context.WithValue(context.Background(), tenantKey{}, tenant), &out, "whoami"))
+				check.Equal(tenant, out)
+			}(tenant)
+		}
+		wg.Wait()
+	})
+
+	t.Run("batch should be sent with the headers and trace context of its calls", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":1}`))
+		client := NewAutoBatchClient(NewClient(s.URL), nil)
+
+		tc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		check.Nil(err)
+		ctx := WithHeaders(ContextWithTraceContext(context.Background(), tc), map[string]string{"X-Tenant": "a"})
+
+		_, err = client.Call(ctx, "something")
+		check.Nil(err)
+		headers := <-s.headers
+		check.Equal("a", headers.Get("X-Tenant"))
+		check.Equal(tc.Traceparent(), headers.Get("traceparent"))
+	})
+
+	t.Run("batch calls should be passed through", func(t *testing.T) {
+		sizes := make(chan int, 10)
+		s := newTestServer(t, echo(sizes))
+		client := NewAutoBatchClient(NewClient(s.URL), nil)
+
+		res, err := client.CallBatch(context.Background(), RPCRequests{NewRequest("echo", 1), NewRequest("echo", 2)})
+		check.Nil(err)
+		check.Len(res, 2)
+		check.Equal(2, <-sizes)
+	})
+}
@@ -0,0 +1,377 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoEndpoints is returned by a BalancedClient if there is no endpoint to send a request to.
+var ErrNoEndpoints = errors.New("no endpoints available")
+
+// Strategy defines how a BalancedClient chooses the endpoint for the next request.
+type Strategy int
+
+const (
+	// StrategyRoundRobin sends requests to all endpoints in turn.
+	StrategyRoundRobin Strategy = iota
+	// StrategyRandom sends every request to a randomly chosen endpoint.
+	StrategyRandom
+	// StrategyWeighted distributes requests proportional to Endpoint.Weight (smooth weighted round robin).
+	StrategyWeighted
+	// StrategyLeastOutstanding sends requests to the endpoint with the fewest requests in flight.
+	StrategyLeastOutstanding
+	// StrategyFailover sends all requests to the first healthy endpoint in the order they were provided (primary/secondary).
+	StrategyFailover
+)
+
+// Endpoint describes a single JSON-RPC backend of a BalancedClient.
+//
+// URL: JSON-RPC service URL to which JSON-RPC requests are sent.
+//
+// Weight: relative weight used by StrategyWeighted. Values less than 1 are treated as 1.
+//
+// CustomHeaders: headers that are only sent to this endpoint (e.g. credentials).
+// They are merged with and take precedence over RPCClientOpts.CustomHeaders.
+type Endpoint struct {
+	URL           string
+	Weight        int
+	CustomHeaders map[string]string
+}
+
+// BalancedClientOpts can be provided to NewBalancedClient() to change configuration of a BalancedClient.
+//
+// Strategy: the load balancing strategy, defaults to StrategyRoundRobin.
+//
+// ClientOpts: configuration that is used for the RPCClient of every endpoint.
+// Its Hooks.OnRetry is also called when a call fails over to another endpoint.
+// RateLimit, Bulkhead and AdaptiveLimiter are shared by all endpoints, so they limit the BalancedClient as a whole.
+//
+// IdempotentMethods: methods that are safe to be sent more than once. Calls to these methods
+// fail over to the next healthy endpoint if an endpoint fails with a network or server error.
+// A batch request fails over if all of its methods are idempotent.
+//
+// HealthCheckMethod: rpc method (without params) that is called to probe every endpoint.
+// A probe fails if the call returns an error or an RPCError. Probes are sent directly to the endpoint,
+// bypassing the cache, deduplication, rate limits, bulkhead and adaptive limiter of ClientOpts.
+//
+// HealthCheckInterval: interval of the background health checks. Health checks are only active
+// if HealthCheckMethod is set and HealthCheckInterval is greater than 0. Use Close() to stop them.
+//
+// HealthCheckTimeout: timeout of a single probe, defaults to HealthCheckInterval.
+//
+// UnhealthyThreshold: consecutive failed probes until an endpoint is considered unhealthy, defaults to 1.
+//
+// HealthyThreshold: consecutive successful probes until an unhealthy endpoint is restored, defaults to 1.
+//
+// HedgeDelay: if greater than 0, calls to IdempotentMethods that did not respond within HedgeDelay
+// are sent a second time to another endpoint (or the same one if there is no other).
+// The first successful response is used, all other requests are canceled.
+//
+// HedgePercentile: if between 0 and 1, the hedge delay of a method is the given percentile (e.g. 0.95)
+// of its recently observed latencies. HedgeDelay is used until enough latencies were observed,
+// without HedgeDelay calls are not hedged until then.
+//
+// MaxHedges: maximum number of additional requests per call, defaults to 1.
+type BalancedClientOpts struct {
+	Strategy            Strategy
+	ClientOpts          *RPCClientOpts
+	IdempotentMethods   []string
+	HealthCheckMethod   string
+	HealthCheckInterval time.Duration
+	HealthCheckTimeout  time.Duration
+	UnhealthyThreshold  int
+	HealthyThreshold    int
+	HedgeDelay          time.Duration
+	HedgePercentile     float64
+	MaxHedges           int
+}
+
+// BalancedClient is an RPCClient that spreads requests over a list of equivalent endpoints.
+//
+// Every single request and every batch request is sent to exactly one endpoint.
+// Unhealthy endpoints are skipped as long as at least one endpoint is healthy.
+//
+// BalancedClient is created using the factory function NewBalancedClient().
+type BalancedClient struct {
+	endpoints  []*endpoint
+	strategy   Strategy
+	idempotent map[string]bool
+	health     healthCheck
+	hedge      *hedging
+	hooks      *Hooks
+
+	mu   sync.Mutex
+	next int
+}
+
+type endpoint struct {
+	url         string
+	weight      int
+	client      *rpcClient
+	outstanding int64
+
+	// current weight for smooth weighted round robin, guarded by BalancedClient.mu
+	currentWeight int
+
+	status endpointStatus
+}
+
+// NewBalancedClient returns a new BalancedClient that sends requests to the given endpoints.
+//
+// endpoints: list of equivalent JSON-RPC service endpoints.
+//
+// opts: BalancedClientOpts is used to provide custom configuration, can be nil.
+func NewBalancedClient(endpoints []Endpoint, opts *BalancedClientOpts) *BalancedClient {
+	if opts == nil {
+		opts = &BalancedClientOpts{}
+	}
+
+	balancedClient := &BalancedClient{
+		strategy:   opts.Strategy,
+		idempotent: make(map[string]bool),
+	}
+
+	for _, method := range opts.IdempotentMethods {
+		balancedClient.idempotent[method] = true
+	}
+
+	balancedClient.hedge = newHedging(opts)
+
+	// the rate limit applies to the BalancedClient as a whole, like the shared Bulkhead and AdaptiveLimiter
+	var limiter *rateLimiter
+	if opts.ClientOpts != nil {
+		balancedClient.hooks = opts.ClientOpts.Hooks
+		limiter = newRateLimiter(opts.ClientOpts.RateLimit)
+	}
+
+	for _, e := range endpoints {
+		clientOpts := &RPCClientOpts{}
+		if opts.ClientOpts != nil {
+			*clientOpts = *opts.ClientOpts
+		}
+
+		clientOpts.CustomHeaders = make(map[string]string)
+		if opts.ClientOpts != nil {
+			for k, v := range opts.ClientOpts.CustomHeaders {
+				clientOpts.CustomHeaders[k] = v
+			}
+		}
+		for k, v := range e.CustomHeaders {
+			clientOpts.CustomHeaders[k] = v
+		}
+
+		weight := e.Weight
+		if weight < 1 {
+			weight = 1
+		}
+
+		client := newClient(e.URL, clientOpts)
+		client.rateLimiter = limiter
+
+		balancedClient.endpoints = append(balancedClient.endpoints, &endpoint{
+			url:    e.URL,
+			weight: weight,
+			client: client,
+			status: endpointStatus{healthy: true},
+		})
+	}
+
+	balancedClient.startHealthCheck(opts)
+
+	return balancedClient
+}
+
+// Call sends a JSON-RPC request to one of the endpoints. See RPCClient.Call().
+func (b *BalancedClient) Call(ctx context.Context, method string, params ...interface{}) (*RPCResponse, error) {
+	res, err := b.run(ctx, method, b.idempotent[method], func(ctx context.Context, client RPCClient) (interface{}, error) {
+		return client.Call(ctx, method, params...)
+	})
+	rpcResponse, _ := res.(*RPCResponse)
+	return rpcResponse, err
+}
+
+// CallRaw sends the given RPCRequest to one of the endpoints. See RPCClient.CallRaw().
+func (b *BalancedClient) CallRaw(ctx context.Context, request *RPCRequest) (*RPCResponse, error) {
+	res, err := b.run(ctx, request.Method, b.idempotent[request.Method], func(ctx context.Context, client RPCClient) (interface{}, error) {
+		return client.CallRaw(ctx, request)
+	})
+	rpcResponse, _ := res.(*RPCResponse)
+	return rpcResponse, err
+}
+
+// CallFor sends a JSON-RPC request to one of the endpoints and stores the result in out. See RPCClient.CallFor().
+func (b *BalancedClient) CallFor(ctx context.Context, out interface{}, method string, params ...interface{}) error {
+	rpcResponse, err := b.Call(ctx, method, params...)
+	if err != nil {
+		return err
+	}
+
+	if rpcResponse.Error != nil {
+		return rpcResponse.Error
+	}
+
+	return rpcResponse.GetObject(out)
+}
+
+// CallBatch sends all requests as one batch to a single endpoint. See RPCClient.CallBatch().
+func (b *BalancedClient) CallBatch(ctx context.Context, requests RPCRequests) (RPCResponses, error) {
+	if len(requests) == 0 {
+		return nil, errors.New("empty request list")
+	}
+
+	for i, req := range requests {
+		req.ID = i
+		req.JSONRPC = jsonrpcVersion
+	}
+
+	return b.CallBatchRaw(ctx, requests)
+}
+
+// CallBatchRaw sends all requests as one batch to a single endpoint. See RPCClient.CallBatchRaw().
+func (b *BalancedClient) CallBatchRaw(ctx context.Context, requests RPCRequests) (RPCResponses, error) {
+	res, err := b.run(ctx, batchMethod, b.isIdempotentBatch(requests), func(ctx context.Context, client RPCClient) (interface{}, error) {
+		return client.CallBatchRaw(ctx, requests)
+	})
+	rpcResponses, _ := res.(RPCResponses)
+	return rpcResponses, err
+}
+
+// endpointCall sends a request to the given client and returns its *RPCResponse or RPCResponses.
+type endpointCall func(ctx context.Context, client RPCClient) (interface{}, error)
+
+// run picks an endpoint and invokes call with its client while tracking the outstanding requests.
+//
+// If idempotent is true, failed calls are repeated on the remaining endpoints and hedged if configured.
+func (b *BalancedClient) run(ctx context.Context, method string, idempotent bool, call endpointCall) (interface{}, error) {
+	candidates := b.available()
+
+	if idempotent && b.hedge.enabled() {
+		return b.runHedged(ctx, method, candidates, call)
+	}
+
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		e := b.pick(candidates)
+		if e == nil {
+			return nil, ErrNoEndpoints
+		}
+		if attempt > 0 {
+			b.hooks.retry(ctx, method, attempt, redactURL(e.url), lastErr)
+		}
+
+		res, err := b.invoke(ctx, e, call)
+		if err == nil || !idempotent || !shouldFailover(ctx, err) {
+			return res, err
+		}
+
+		candidates = without(candidates, e)
+		if len(candidates) == 0 {
+			return res, err
+		}
+		lastErr = err
+	}
+}
+
+// invoke calls the given endpoint while tracking the outstanding requests.
+func (b *BalancedClient) invoke(ctx context.Context, e *endpoint, call endpointCall) (interface{}, error) {
+	atomic.AddInt64(&e.outstanding, 1)
+	defer atomic.AddInt64(&e.outstanding, -1)
+
+	return call(ctx, e.client)
+}
+
+// available returns all healthy endpoints, or all endpoints if none of them is healthy.
+func (b *BalancedClient) available() []*endpoint {
+	healthy := make([]*endpoint, 0, len(b.endpoints))
+	for _, e := range b.endpoints {
+		if e.status.isHealthy() {
+			healthy = append(healthy, e)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return b.endpoints
+	}
+
+	return healthy
+}
+
+func (b *BalancedClient) isIdempotentBatch(requests RPCRequests) bool {
+	for _, req := range requests {
+		if req == nil || !b.idempotent[req.Method] {
+			return false
+		}
+	}
+
+	return len(requests) > 0
+}
+
+func (e *endpoint) outstandingRequests() int64 {
+	return atomic.LoadInt64(&e.outstanding)
+}
+
+func without(endpoints []*endpoint, e *endpoint) []*endpoint {
+	res := make([]*endpoint, 0, len(endpoints))
+	for _, candidate := range endpoints {
+		if candidate != e {
+			res = append(res, candidate)
+		}
+	}
+
+	return res
+}
+
+// pick chooses one of the candidates according to the configured strategy, nil if there is none.
+func (b *BalancedClient) pick(candidates []*endpoint) *endpoint {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	switch b.strategy {
+	case StrategyRandom:
+		return candidates[rand.Intn(len(candidates))]
+	case StrategyWeighted:
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		var best *endpoint
+		total := 0
+		for _, e := range candidates {
+			e.currentWeight += e.weight
+			total += e.weight
+			if best == nil || e.currentWeight > best.currentWeight {
+				best = e
+			}
+		}
+		best.currentWeight -= total
+		return best
+	case StrategyLeastOutstanding:
+		b.mu.Lock()
+		offset := b.next
+		b.next++
+		b.mu.Unlock()
+
+		// start at a rotating offset so that ties are not always resolved to the first endpoint
+		var best *endpoint
+		for i := range candidates {
+			e := candidates[(offset+i)%len(candidates)]
+			if best == nil || e.outstandingRequests() < best.outstandingRequests() {
+				best = e
+			}
+		}
+		return best
+	case StrategyFailover:
+		return candidates[0]
+	default:
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		e := candidates[b.next%len(candidates)]
+		b.next++
+		return e
+	}
+}
@@ -0,0 +1,133 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalancedClient(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("round robin should alternate endpoints", func(t *testing.T) {
+		s1 := newTestServer(t, respond(http.StatusOK, `{"result":1}`))
+		s2 := newTestServer(t, respond(http.StatusOK, `{"result":2}`))
+		client := NewBalancedClient([]Endpoint{{URL: s1.URL}, {URL: s2.URL}}, nil)
+
+		for i := 0; i < 4; i++ {
+			res, err := client.Call(context.Background(), "something")
+			check.Nil(err)
+			check.NotNil(res)
+		}
+		check.Equal(2, s1.requests())
+		check.Equal(2, s2.requests())
+	})
+
+	t.Run("weighted should respect endpoint weights", func(t *testing.T) {
+		s1 := newTestServer(t, respond(http.StatusOK, `{"result":1}`))
+		s2 := newTestServer(t, respond(http.StatusOK, `{"result":2}`))
+		client := NewBalancedClient([]Endpoint{{URL: s1.URL, Weight: 3}, {URL: s2.URL, Weight: 1}}, &BalancedClientOpts{Strategy: StrategyWeighted})
+
+		for i := 0; i < 8; i++ {
+			_, err := client.Call(context.Background(), "something")
+			check.Nil(err)
+		}
+		check.Equal(6, s1.requests())
+		check.Equal(2, s2.requests())
+	})
+
+	t.Run("random should only use known endpoints", func(t *testing.T) {
+		s1 := newTestServer(t, respond(http.StatusOK, `{"result":1}`))
+		s2 := newTestServer(t, respond(http.StatusOK, `{"result":2}`))
+		client := NewBalancedClient([]Endpoint{{URL: s1.URL}, {URL: s2.URL}}, &BalancedClientOpts{Strategy: StrategyRandom})
+
+		for i := 0; i < 10; i++ {
+			_, err := client.Call(context.Background(), "something")
+			check.Nil(err)
+		}
+		check.Equal(10, s1.requests()+s2.requests())
+	})
+
+	t.Run("least outstanding should avoid busy endpoints", func(t *testing.T) {
+		block := make(chan struct{})
+		busy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-block
+			w.Write([]byte(`{"result":1}`))
+		}))
+		defer busy.Close()
+		defer close(block)
+		idle := newTestServer(t, respond(http.StatusOK, `{"result":2}`))
+
+		client := NewBalancedClient([]Endpoint{{URL: busy.URL}, {URL: idle.URL}}, &BalancedClientOpts{Strategy: StrategyLeastOutstanding})
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			client.Call(ctx, "slow")
+		}()
+
+		// wait until the slow call is in flight on one of the endpoints
+		for atomic.LoadInt64(&client.endpoints[0].outstanding)+atomic.LoadInt64(&client.endpoints[1].outstanding) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		// ties are resolved starting with the first endpoint, so the slow call blocks the busy endpoint
+		check.Equal(int64(1), atomic.LoadInt64(&client.endpoints[0].outstanding))
+		for i := 0; i < 3; i++ {
+			_, err := client.Call(context.Background(), "fast")
+			check.Nil(err)
+		}
+		check.Equal(3, idle.requests())
+	})
+
+	t.Run("per endpoint headers should be merged over client headers", func(t *testing.T) {
+		s1 := newTestServer(t, respond(http.StatusOK, `{"result":1}`))
+		s2 := newTestServer(t, respond(http.StatusOK, `{"result":2}`))
+		client := NewBalancedClient([]Endpoint{
+			{URL: s1.URL, CustomHeaders: map[string]string{"Authorization": "Bearer one"}},
+			{URL: s2.URL, CustomHeaders: map[string]string{"Authorization": "Bearer two"}},
+		}, &BalancedClientOpts{ClientOpts: &RPCClientOpts{CustomHeaders: map[string]string{
+			"Authorization": "Bearer default",
+			"X-Shared":      "shared",
+		}}})
+
+		client.Call(context.Background(), "something")
+		client.Call(context.Background(), "something")
+
+		h1 := <-s1.headers
+		h2 := <-s2.headers
+		check.Equal("Bearer one", h1.Get("Authorization"))
+		check.Equal("Bearer two", h2.Get("Authorization"))
+		check.Equal("shared", h1.Get("X-Shared"))
+		check.Equal("shared", h2.Get("X-Shared"))
+	})
+
+	t.Run("batch should be sent to a single endpoint", func(t *testing.T) {
+		s1 := newTestServer(t, respond(http.StatusOK, `[{"result":1,"id":0},{"result":2,"id":1}]`))
+		s2 := newTestServer(t, respond(http.StatusOK, `[{"result":1,"id":0},{"result":2,"id":1}]`))
+		client := NewBalancedClient([]Endpoint{{URL: s1.URL}, {URL: s2.URL}}, nil)
+
+		res, err := client.CallBatch(context.Background(), RPCRequests{
+			NewRequest("first"),
+			NewRequest("second"),
+		})
+		check.Nil(err)
+		check.Len(res, 2)
+		check.Equal(1, s1.requests()+s2.requests())
+	})
+
+	t.Run("no endpoints should return error", func(t *testing.T) {
+		client := NewBalancedClient(nil, nil)
+		_, err := client.Call(context.Background(), "something")
+		check.ErrorIs(err, ErrNoEndpoints)
+	})
+}
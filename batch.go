@@ -0,0 +1,129 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Batch is a builder for batch requests that stores the result of every request in its own target value.
+//
+// e.g.
+//
+//	batch := NewBatch()
+//	var person *Person
+//	var count int
+//	personEntry := batch.Add(&person, "getPersonById", 4711)
+//	countEntry := batch.Add(&count, "countPersons")
+//	err := batch.Run(ctx, rpcClient) // aggregated error of all entries
+//	if personEntry.Err() != nil { ... }
+//
+// A Batch is not safe for concurrent use.
+type Batch struct {
+	entries []*BatchEntry
+}
+
+// BatchEntry is a single request of a Batch.
+type BatchEntry struct {
+	request  *RPCRequest
+	out      interface{}
+	response *RPCResponse
+	err      error
+}
+
+// NewBatch returns a new empty Batch.
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+// Add adds a request to the batch.
+//
+// out: will store the unmarshaled result when the batch was run (see CallFor()), can be nil.
+//
+// method and params: see Call() function
+func (b *Batch) Add(out interface{}, method string, params ...interface{}) *BatchEntry {
+	entry := &BatchEntry{
+		request: NewRequest(method, params...),
+		out:     out,
+	}
+	b.entries = append(b.entries, entry)
+
+	return entry
+}
+
+// Len returns the number of entries in the batch.
+func (b *Batch) Len() int {
+	return len(b.entries)
+}
+
+// Run sends all entries as one batch request with CallBatch() and stores the results in their targets.
+//
+// The returned error joins the errors of all entries (see errors.Join()), nil if all entries succeeded.
+// If the whole batch request failed, every entry holds that error.
+func (b *Batch) Run(ctx context.Context, client RPCClient) error {
+	if len(b.entries) == 0 {
+		return errors.New("empty request list")
+	}
+
+	requests := make(RPCRequests, len(b.entries))
+	for i, entry := range b.entries {
+		entry.response = nil
+		entry.err = nil
+		requests[i] = entry.request
+	}
+
+	// CallBatch sets the id of every request to its index
+	responses, err := client.CallBatch(ctx, requests)
+	byID := responses.AsMap()
+
+	errs := make([]error, 0)
+	for i, entry := range b.entries {
+		entry.response = byID[i]
+		entry.err = entry.result(err)
+		if entry.err != nil {
+			errs = append(errs, fmt.Errorf("batch entry %v %v(): %w", i, entry.request.Method, entry.err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// result stores the response in the target and returns the error of the entry.
+func (e *BatchEntry) result(batchErr error) error {
+	if e.response == nil {
+		if batchErr != nil {
+			return batchErr
+		}
+		return errResponseMissing
+	}
+
+	if e.response.Error != nil {
+		return e.response.Error
+	}
+
+	if e.out != nil {
+		if err := e.response.GetObject(e.out); err != nil {
+			return err
+		}
+	}
+
+	// the response is valid, but the http status code signaled an error
+	return batchErr
+}
+
+// Err returns the error of the entry after the batch was run, nil if it succeeded.
+//
+// If it was an JSON-RPC error it can be casted to *RPCError.
+func (e *BatchEntry) Err() error {
+	return e.err
+}
+
+// Response returns the response of the entry after the batch was run, nil if there was none.
+func (e *BatchEntry) Response() *RPCResponse {
+	return e.response
+}
+
+// Request returns the request of the entry.
+func (e *BatchEntry) Request() *RPCRequest {
+	return e.request
+}
@@ -0,0 +1,97 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("results should be stored in their targets", func(t *testing.T) {
+		sizes := make(chan int, 10)
+		s := newTestServer(t, echo(sizes))
+
+		batch := NewBatch()
+		var name string
+		var age int
+		var person Person
+		nameEntry := batch.Add(&name, "echo", "Alex")
+		ageEntry := batch.Add(&age, "echo", 35)
+		personEntry := batch.Add(&person, "echo", []interface{}{Person{Name: "Alex", Age: 35, Country: "Germany"}})
+		batch.Add(nil, "echo", "ignored")
+		check.Equal(4, batch.Len())
+
+		err := batch.Run(context.Background(), NewClient(s.URL))
+		check.Nil(err)
+		check.Equal(4, <-sizes)
+
+		check.Nil(nameEntry.Err())
+		check.Nil(ageEntry.Err())
+		check.Nil(personEntry.Err())
+		check.Equal("Alex", name)
+		check.Equal(35, age)
+		check.Equal(Person{Name: "Alex", Age: 35, Country: "Germany"}, person)
+		check.Equal(1, ageEntry.Request().ID)
+		check.Equal(1, ageEntry.Response().ID)
+	})
+
+	t.Run("entries should hold their own errors", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `[{"result":"ok","id":0},{"error":{"code":123,"message":"something wrong"},"id":1},{"result":"no number","id":2}]`))
+
+		batch := NewBatch()
+		var ok string
+		var failed string
+		var number int
+		okEntry := batch.Add(&ok, "first")
+		failedEntry := batch.Add(&failed, "second")
+		numberEntry := batch.Add(&number, "third")
+		missingEntry := batch.Add(nil, "fourth")
+
+		err := batch.Run(context.Background(), NewClient(s.URL))
+		check.NotNil(err)
+
+		check.Nil(okEntry.Err())
+		check.Equal("ok", ok)
+
+		var rpcErr *RPCError
+		check.True(errors.As(failedEntry.Err(), &rpcErr))
+		check.Equal(123, rpcErr.Code)
+		check.True(errors.As(err, &rpcErr))
+
+		check.NotNil(numberEntry.Err())
+		check.NotNil(missingEntry.Err())
+		check.Nil(missingEntry.Response())
+		check.Contains(err.Error(), "batch entry 1 second(): 123: something wrong")
+		check.Contains(err.Error(), "batch entry 3 fourth(): rpc response missing")
+	})
+
+	t.Run("failed batch request should fail every entry", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`<html>bad gateway</html>`))
+		}))
+		defer s.Close()
+
+		batch := NewBatch()
+		first := batch.Add(nil, "first")
+		second := batch.Add(nil, "second")
+
+		err := batch.Run(context.Background(), NewClient(s.URL))
+		check.NotNil(err)
+
+		var httpErr *HTTPError
+		check.True(errors.As(first.Err(), &httpErr))
+		check.Equal(http.StatusBadGateway, httpErr.Code)
+		check.True(errors.As(second.Err(), &httpErr))
+	})
+
+	t.Run("empty batch should return error", func(t *testing.T) {
+		check.NotNil(NewBatch().Run(context.Background(), NewClient("http://localhost")))
+	})
+}
@@ -0,0 +1,218 @@
+package jsonrpc
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBulkheadFull is returned if a call can not be queued because the queue of a Bulkhead is full.
+var ErrBulkheadFull = errors.New("too many queued requests")
+
+// Priority is the priority class of a call. Calls with higher priority are dequeued first by a Bulkhead.
+type Priority int
+
+const (
+	// PriorityLow is used for bulk jobs that may wait behind all other calls.
+	PriorityLow Priority = -1
+	// PriorityNormal is the default priority of all calls.
+	PriorityNormal Priority = 0
+	// PriorityHigh is used for calls that should not wait behind other calls, e.g. user facing requests.
+	PriorityHigh Priority = 1
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx that holds the priority class for calls made with it.
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+func priorityFrom(ctx context.Context) Priority {
+	priority, _ := ctx.Value(priorityKey{}).(Priority)
+	return priority
+}
+
+// BulkheadStats holds statistics of a Bulkhead.
+//
+// InFlight: number of calls currently in flight.
+//
+// QueueDepth: number of calls currently waiting, MaxQueueDepth: highest observed queue depth.
+//
+// Acquired: number of calls that were admitted, Queued: how many of them had to wait.
+//
+// Rejected: number of calls rejected because the queue was full, Canceled: number of calls whose context was done while waiting.
+//
+// TotalWaitTime, MaxWaitTime: time spent waiting in the queue by admitted calls.
+type BulkheadStats struct {
+	InFlight      int
+	QueueDepth    int
+	MaxQueueDepth int
+	Acquired      uint64
+	Queued        uint64
+	Rejected      uint64
+	Canceled      uint64
+	TotalWaitTime time.Duration
+	MaxWaitTime   time.Duration
+}
+
+// Bulkhead caps the number of concurrent in-flight requests.
+//
+// Calls that exceed the cap are queued until a slot becomes available or their context is done.
+// The queue is ordered by priority (see WithPriority()) and then by arrival.
+//
+// A Bulkhead is provided with RPCClientOpts.Bulkhead and may be shared by multiple clients.
+// It is created using the factory function NewBulkhead().
+type Bulkhead struct {
+	maxInFlight int
+	maxQueue    int
+
+	mu    sync.Mutex
+	queue waiterQueue
+	seq   uint64
+	stats BulkheadStats
+}
+
+// NewBulkhead returns a new Bulkhead.
+//
+// maxInFlight: maximum number of concurrent requests, values less than 1 are treated as 1.
+//
+// maxQueue: maximum number of waiting requests, 0 means unlimited.
+func NewBulkhead(maxInFlight int, maxQueue int) *Bulkhead {
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+
+	return &Bulkhead{
+		maxInFlight: maxInFlight,
+		maxQueue:    maxQueue,
+	}
+}
+
+// Stats returns a snapshot of the current statistics.
+func (b *Bulkhead) Stats() BulkheadStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := b.stats
+	stats.QueueDepth = len(b.queue)
+	return stats
+}
+
+// acquire waits for a free slot. The returned function must be called to release the slot.
+func (b *Bulkhead) acquire(ctx context.Context) (func(), error) {
+	if b == nil {
+		return func() {}, nil
+	}
+
+	b.mu.Lock()
+	if b.stats.InFlight < b.maxInFlight && len(b.queue) == 0 {
+		b.stats.InFlight++
+		b.stats.Acquired++
+		b.mu.Unlock()
+		return b.release, nil
+	}
+
+	if b.maxQueue > 0 && len(b.queue) >= b.maxQueue {
+		b.stats.Rejected++
+		b.mu.Unlock()
+		return nil, ErrBulkheadFull
+	}
+
+	b.seq++
+	w := &waiter{
+		priority: priorityFrom(ctx),
+		seq:      b.seq,
+		ready:    make(chan struct{}),
+	}
+	heap.Push(&b.queue, w)
+	if len(b.queue) > b.stats.MaxQueueDepth {
+		b.stats.MaxQueueDepth = len(b.queue)
+	}
+	b.mu.Unlock()
+
+	start := time.Now()
+	select {
+	case <-w.ready:
+	case <-ctx.Done():
+		b.mu.Lock()
+		if w.index >= 0 {
+			heap.Remove(&b.queue, w.index)
+			b.stats.Canceled++
+			b.mu.Unlock()
+			return nil, ctx.Err()
+		}
+		// the slot was granted concurrently, so the call may still proceed
+		b.mu.Unlock()
+	}
+
+	wait := time.Since(start)
+
+	b.mu.Lock()
+	b.stats.Acquired++
+	b.stats.Queued++
+	b.stats.TotalWaitTime += wait
+	if wait > b.stats.MaxWaitTime {
+		b.stats.MaxWaitTime = wait
+	}
+	b.mu.Unlock()
+
+	return b.release, nil
+}
+
+// release hands the slot over to the next waiter or frees it.
+func (b *Bulkhead) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.queue) > 0 {
+		w := heap.Pop(&b.queue).(*waiter)
+		close(w.ready)
+		return
+	}
+
+	b.stats.InFlight--
+}
+
+type waiter struct {
+	priority Priority
+	seq      uint64
+	ready    chan struct{}
+	index    int
+}
+
+// waiterQueue implements heap.Interface ordered by priority and arrival.
+type waiterQueue []*waiter
+
+func (q waiterQueue) Len() int { return len(q) }
+
+func (q waiterQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waiterQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waiterQueue) Push(x interface{}) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waiterQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*q = old[:n-1]
+	return w
+}
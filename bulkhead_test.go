@@ -0,0 +1,130 @@
+package jsonrpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkhead(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("client should not exceed max in flight requests", func(t *testing.T) {
+		var current, max int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.ReadAll(r.Body)
+			n := atomic.AddInt32(&current, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&current, -1)
+			w.Write([]byte(`{"result":1}`))
+		}))
+		defer s.Close()
+
+		bulkhead := NewBulkhead(2, 0)
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Bulkhead: bulkhead})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := rpcClient.Call(context.Background(), "something")
+				check.Nil(err)
+			}()
+		}
+		wg.Wait()
+
+		check.Equal(int32(2), atomic.LoadInt32(&max))
+		stats := bulkhead.Stats()
+		check.Equal(0, stats.InFlight)
+		check.Equal(0, stats.QueueDepth)
+		check.Equal(uint64(8), stats.Acquired)
+		check.Equal(uint64(6), stats.Queued)
+		check.Greater(stats.TotalWaitTime, time.Duration(0))
+		check.GreaterOrEqual(stats.MaxQueueDepth, 1)
+	})
+
+	t.Run("high priority calls should be dequeued first", func(t *testing.T) {
+		bulkhead := NewBulkhead(1, 0)
+		release, err := bulkhead.acquire(context.Background())
+		check.Nil(err)
+
+		order := make(chan Priority, 3)
+		var wg sync.WaitGroup
+		enqueue := func(priority Priority) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				release, err := bulkhead.acquire(WithPriority(context.Background(), priority))
+				check.Nil(err)
+				order <- priority
+				release()
+			}()
+		}
+
+		enqueue(PriorityLow)
+		check.Eventually(func() bool { return bulkhead.Stats().QueueDepth == 1 }, time.Second, time.Millisecond)
+		enqueue(PriorityNormal)
+		check.Eventually(func() bool { return bulkhead.Stats().QueueDepth == 2 }, time.Second, time.Millisecond)
+		enqueue(PriorityHigh)
+		check.Eventually(func() bool { return bulkhead.Stats().QueueDepth == 3 }, time.Second, time.Millisecond)
+
+		release()
+		wg.Wait()
+
+		check.Equal(PriorityHigh, <-order)
+		check.Equal(PriorityNormal, <-order)
+		check.Equal(PriorityLow, <-order)
+	})
+
+	t.Run("full queue should reject calls", func(t *testing.T) {
+		bulkhead := NewBulkhead(1, 1)
+		release, err := bulkhead.acquire(context.Background())
+		check.Nil(err)
+		defer release()
+
+		go bulkhead.acquire(context.Background())
+		check.Eventually(func() bool { return bulkhead.Stats().QueueDepth == 1 }, time.Second, time.Millisecond)
+
+		s := newTestServer(t, respond(http.StatusOK, `{"result":1}`))
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Bulkhead: bulkhead})
+		_, err = rpcClient.Call(context.Background(), "something")
+		check.ErrorIs(err, ErrBulkheadFull)
+		check.Equal(uint64(1), bulkhead.Stats().Rejected)
+		check.Equal(0, s.requests())
+	})
+
+	t.Run("queued calls should respect the context deadline", func(t *testing.T) {
+		bulkhead := NewBulkhead(1, 0)
+		release, err := bulkhead.acquire(context.Background())
+		check.Nil(err)
+
+		s := newTestServer(t, respond(http.StatusOK, `{"result":1}`))
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Bulkhead: bulkhead})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err = rpcClient.Call(ctx, "something")
+		check.ErrorIs(err, context.DeadlineExceeded)
+
+		stats := bulkhead.Stats()
+		check.Equal(uint64(1), stats.Canceled)
+		check.Equal(0, stats.QueueDepth)
+
+		release()
+		check.Equal(0, bulkhead.Stats().InFlight)
+	})
+}
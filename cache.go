@@ -0,0 +1,309 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStore is the storage of a ResponseCache.
+//
+// Implementations must be safe for concurrent use.
+// Responses are stored as json encoded RPCResponse, every hit is decoded into a new RPCResponse.
+type CacheStore interface {
+	// Get returns the response stored for key, false if there is none or it expired.
+	Get(key string) ([]byte, bool)
+	// Set stores the response for key, it expires after ttl.
+	Set(key string, response []byte, ttl time.Duration)
+}
+
+// CacheOpts can be provided to NewResponseCache() to change the configuration of a ResponseCache.
+//
+// Store: storage of the cached responses, defaults to an in-memory LRU store with 1000 entries (see NewLRUCacheStore()).
+//
+// TTLs: methods whose responses are cached together with the time they stay valid.
+// Only these methods are cached, so they should be idempotent.
+//
+// NegativeCodes: RPCError codes whose responses are cached as well (negative caching).
+// All other responses with an RPCError are not cached.
+//
+// NegativeTTL: time negative responses stay valid, defaults to the TTL of the method.
+type CacheOpts struct {
+	Store         CacheStore
+	TTLs          map[string]time.Duration
+	NegativeCodes []int
+	NegativeTTL   time.Duration
+}
+
+// CacheStats holds statistics of a ResponseCache.
+//
+// Hits: calls answered from the cache, NegativeHits: how many of them were answered with an RPCError.
+//
+// Misses: cacheable calls that were sent to the server, Bypassed: calls that skipped the cache (see WithCacheBypass()).
+type CacheStats struct {
+	Hits         uint64
+	NegativeHits uint64
+	Misses       uint64
+	Bypassed     uint64
+}
+
+// ResponseCache caches responses of idempotent methods keyed by client (see below), per call headers (see HeaderProvider and
+// WithHeaders()), method and canonicalized params.
+//
+// A ResponseCache is provided with RPCClientOpts.Cache and may be shared by multiple clients.
+// Clients only share responses if they have the same endpoint, CustomHeaders and AuthProvider,
+// clients with an AuthProvider that is not a pointer never share responses.
+// Batch requests are not cached.
+// It is created using the factory function NewResponseCache().
+type ResponseCache struct {
+	store         CacheStore
+	ttls          map[string]time.Duration
+	negativeCodes map[int]bool
+	negativeTTL   time.Duration
+
+	hits         uint64
+	negativeHits uint64
+	misses       uint64
+	bypassed     uint64
+}
+
+// NewResponseCache returns a new ResponseCache.
+//
+// opts: CacheOpts is used to provide custom configuration, can be nil (nothing is cached until TTLs are set).
+func NewResponseCache(opts *CacheOpts) *ResponseCache {
+	if opts == nil {
+		opts = &CacheOpts{}
+	}
+
+	cache := &ResponseCache{
+		store:         opts.Store,
+		ttls:          make(map[string]time.Duration),
+		negativeCodes: make(map[int]bool),
+		negativeTTL:   opts.NegativeTTL,
+	}
+
+	if cache.store == nil {
+		cache.store = NewLRUCacheStore(1000)
+	}
+
+	for method, ttl := range opts.TTLs {
+		if ttl > 0 {
+			cache.ttls[method] = ttl
+		}
+	}
+
+	for _, code := range opts.NegativeCodes {
+		cache.negativeCodes[code] = true
+	}
+
+	return cache
+}
+
+// Stats returns a snapshot of the cache statistics.
+func (c *ResponseCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:         atomic.LoadUint64(&c.hits),
+		NegativeHits: atomic.LoadUint64(&c.negativeHits),
+		Misses:       atomic.LoadUint64(&c.misses),
+		Bypassed:     atomic.LoadUint64(&c.bypassed),
+	}
+}
+
+type cacheBypassKey struct{}
+
+// WithCacheBypass returns a copy of ctx that makes calls skip the cache lookup.
+// The fresh response is still stored in the cache.
+func WithCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+func cacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(cacheBypassKey{}).(bool)
+	return bypass
+}
+
+func (c *ResponseCache) enabled(method string) bool {
+	_, ok := c.ttls[method]
+	return ok
+}
+
+// cacheScope returns the part of the cache keys of client that separates it from clients with other endpoints or credentials.
+// Endpoint and headers may hold credentials, so they are hashed instead of being exposed to the store.
+func cacheScope(client *rpcClient) string {
+	// the identity of a pointer is known, any other AuthProvider may hold different credentials for each client
+	auth := ""
+	if client.auth != nil {
+		if value := reflect.ValueOf(client.auth); value.Kind() == reflect.Pointer {
+			auth = fmt.Sprintf("%T %x", client.auth, value.Pointer())
+		} else {
+			auth = fmt.Sprintf("client %p", client)
+		}
+	}
+
+	// map keys are encoded in sorted order
+	headers, _ := json.Marshal(client.customHeaders)
+	scope := sha256.Sum256([]byte(client.endpoint + "\x00" + string(headers) + "\x00" + auth))
+	return hex.EncodeToString(scope[:])
+}
+
+// do answers the request from the cache or invokes call and stores its response.
+//
+// scope (see cacheScope()) and headers (see callHeaders()) separate the responses of different clients and callers.
+func (c *ResponseCache) do(ctx context.Context, scope string, headers string, request *RPCRequest, call func(ctx context.Context, request *RPCRequest) (*RPCResponse, error)) (*RPCResponse, error) {
+	ttl, ok := c.ttls[request.Method]
+	if !ok {
+		return call(ctx, request)
+	}
+
+	key, err := requestKey(request)
+	if err != nil {
+		return call(ctx, request)
+	}
+	// headers may hold credentials, so they are hashed instead of being exposed to the store
+	if headers != "" {
+		hashed := sha256.Sum256([]byte(headers))
+		scope += "\x00" + hex.EncodeToString(hashed[:])
+	}
+	key = scope + "\x00" + key
+
+	if cacheBypassed(ctx) {
+		atomic.AddUint64(&c.bypassed, 1)
+	} else if res, ok := c.get(key); ok {
+		atomic.AddUint64(&c.hits, 1)
+		if res.Error != nil {
+			atomic.AddUint64(&c.negativeHits, 1)
+		}
+		res.ID = request.ID
+		return res, nil
+	} else {
+		atomic.AddUint64(&c.misses, 1)
+	}
+
+	res, err := call(ctx, request)
+	if err != nil || res == nil {
+		return res, err
+	}
+
+	if res.Error != nil {
+		if !c.negativeCodes[res.Error.Code] {
+			return res, nil
+		}
+		if c.negativeTTL > 0 {
+			ttl = c.negativeTTL
+		}
+	}
+
+	// the encoded response shares no values with the returned one, so that the caller may modify it
+	if encoded, err := json.Marshal(res); err == nil {
+		c.store.Set(key, encoded, ttl)
+	}
+
+	return res, nil
+}
+
+// get decodes the response stored for key into a new RPCResponse. Undecodable entries are treated as missing.
+func (c *ResponseCache) get(key string) (*RPCResponse, bool) {
+	encoded, ok := c.store.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	res, err := decodeResponse(encoded)
+	if err != nil {
+		return nil, false
+	}
+	return res, true
+}
+
+// decodeResponse decodes a json encoded RPCResponse into a new RPCResponse.
+func decodeResponse(encoded []byte) (*RPCResponse, error) {
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+
+	res := &RPCResponse{}
+	if err := decoder.Decode(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// NewLRUCacheStore returns an in-memory CacheStore that holds at most maxEntries responses
+// and evicts the least recently used one if it is full.
+func NewLRUCacheStore(maxEntries int) CacheStore {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+
+	return &lruCacheStore{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+type lruCacheStore struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type lruEntry struct {
+	key      string
+	response []byte
+	expires  time.Time
+}
+
+func (s *lruCacheStore) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		s.order.Remove(element)
+		delete(s.entries, key)
+		return nil, false
+	}
+
+	s.order.MoveToFront(element)
+	return entry.response, true
+}
+
+func (s *lruCacheStore) Set(key string, response []byte, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.response = response
+		entry.expires = time.Now().Add(ttl)
+		s.order.MoveToFront(element)
+		return
+	}
+
+	s.entries[key] = s.order.PushFront(&lruEntry{
+		key:      key,
+		response: response,
+		expires:  time.Now().Add(ttl),
+	})
+
+	for s.order.Len() > s.maxEntries {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*lruEntry).key)
+	}
+}
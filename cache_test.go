@@ -0,0 +1,218 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mapCacheStore is a CacheStore without expiration to test custom stores
+type mapCacheStore struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+func (s *mapCacheStore) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, ok := s.entries[key]
+	return res, ok
+}
+
+func (s *mapCacheStore) Set(key string, response []byte, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = response
+}
+
+func TestResponseCache(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("responses of cached methods should be served from cache", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":"block","id":0}`))
+		cache := NewResponseCache(&CacheOpts{TTLs: map[string]time.Duration{"getBlock": time.Minute}})
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Cache: cache})
+
+		res, err := rpcClient.Call(context.Background(), "getBlock", 1)
+		check.Nil(err)
+		check.Equal("block", res.Result)
+
+		res, err = rpcClient.CallRaw(context.Background(), NewRequestWithID(5, "getBlock", 1))
+		check.Nil(err)
+		check.Equal("block", res.Result)
+		check.Equal(5, res.ID)
+
+		var out string
+		check.Nil(rpcClient.CallFor(context.Background(), &out, "getBlock", 1))
+		check.Equal("block", out)
+		check.Equal(1, s.requests())
+
+		rpcClient.Call(context.Background(), "getBlock", 2)
+		rpcClient.Call(context.Background(), "sendTransaction", 1)
+		rpcClient.Call(context.Background(), "sendTransaction", 1)
+		check.Equal(4, s.requests())
+
+		stats := cache.Stats()
+		check.Equal(uint64(2), stats.Hits)
+		check.Equal(uint64(2), stats.Misses)
+	})
+
+	t.Run("cached responses should expire", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":"block"}`))
+		cache := NewResponseCache(&CacheOpts{TTLs: map[string]time.Duration{"getBlock": 10 * time.Millisecond}})
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Cache: cache})
+
+		rpcClient.Call(context.Background(), "getBlock", 1)
+		rpcClient.Call(context.Background(), "getBlock", 1)
+		check.Equal(1, s.requests())
+
+		time.Sleep(20 * time.Millisecond)
+		rpcClient.Call(context.Background(), "getBlock", 1)
+		check.Equal(2, s.requests())
+	})
+
+	t.Run("selected rpc errors should be cached", func(t *testing.T) {
+		notFound := newTestServer(t, respond(http.StatusOK, `{"error":{"code":-32001,"message":"not found"}}`))
+		internal := newTestServer(t, respond(http.StatusOK, `{"error":{"code":-32603,"message":"internal error"}}`))
+		cache := NewResponseCache(&CacheOpts{
+			TTLs:          map[string]time.Duration{"getBlock": time.Minute},
+			NegativeCodes: []int{-32001},
+			NegativeTTL:   time.Second,
+		})
+
+		rpcClient := NewClientWithOpts(notFound.URL, &RPCClientOpts{Cache: cache})
+		rpcClient.Call(context.Background(), "getBlock", 1)
+		res, err := rpcClient.Call(context.Background(), "getBlock", 1)
+		check.Nil(err)
+		check.Equal(-32001, res.Error.Code)
+		check.Equal(1, notFound.requests())
+		check.Equal(uint64(1), cache.Stats().NegativeHits)
+
+		rpcClient = NewClientWithOpts(internal.URL, &RPCClientOpts{Cache: cache})
+		rpcClient.Call(context.Background(), "getBlock", 2)
+		rpcClient.Call(context.Background(), "getBlock", 2)
+		check.Equal(2, internal.requests())
+	})
+
+	t.Run("bypass should skip the lookup but refresh the cache", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":"block"}`))
+		store := &mapCacheStore{entries: make(map[string][]byte)}
+		cache := NewResponseCache(&CacheOpts{Store: store, TTLs: map[string]time.Duration{"getBlock": time.Minute}})
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Cache: cache})
+
+		rpcClient.Call(context.Background(), "getBlock", 1)
+		rpcClient.Call(WithCacheBypass(context.Background()), "getBlock", 1)
+		check.Equal(2, s.requests())
+		check.Len(store.entries, 1)
+
+		rpcClient.Call(context.Background(), "getBlock", 1)
+		check.Equal(2, s.requests())
+		check.Equal(uint64(1), cache.Stats().Bypassed)
+	})
+
+	t.Run("returned responses should not modify the cache", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":"block"}`))
+		cache := NewResponseCache(&CacheOpts{TTLs: map[string]time.Duration{"getBlock": time.Minute}})
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Cache: cache})
+
+		res, _ := rpcClient.Call(context.Background(), "getBlock", 1)
+		res.Result = "modified"
+		res, _ = rpcClient.Call(context.Background(), "getBlock", 1)
+		check.Equal("block", res.Result)
+	})
+
+	t.Run("cached values should not be shared between hits", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":{"hash":"0x1"},"error":{"code":-32001,"message":"not found"}}`))
+		cache := NewResponseCache(&CacheOpts{TTLs: map[string]time.Duration{"getBlock": time.Minute}, NegativeCodes: []int{-32001}})
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Cache: cache})
+
+		rpcClient.Call(context.Background(), "getBlock", 1)
+		res, _ := rpcClient.Call(context.Background(), "getBlock", 1)
+		res.Result.(map[string]interface{})["hash"] = "modified"
+		res.Error.Message = "modified"
+
+		res, _ = rpcClient.Call(context.Background(), "getBlock", 1)
+		check.Equal("0x1", res.Result.(map[string]interface{})["hash"])
+		check.Equal("not found", res.Error.Message)
+		check.Equal(1, s.requests())
+	})
+
+	t.Run("responses should be cached per endpoint and per call headers", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":"block"}`))
+		other := newTestServer(t, respond(http.StatusOK, `{"result":"block"}`))
+		cache := NewResponseCache(&CacheOpts{TTLs: map[string]time.Duration{"getBlock": time.Minute}})
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Cache: cache})
+		otherClient := NewClientWithOpts(other.URL, &RPCClientOpts{Cache: cache})
+
+		tenant := func(name string) context.Context {
+			return WithHeaders(context.Background(), map[string]string{"X-Tenant": name})
+		}
+
+		rpcClient.Call(tenant("a"), "getBlock", 1)
+		rpcClient.Call(tenant("a"), "getBlock", 1)
+		rpcClient.Call(tenant("b"), "getBlock", 1)
+		check.Equal(2, s.requests())
+
+		otherClient.Call(tenant("a"), "getBlock", 1)
+		check.Equal(1, other.requests())
+	})
+
+	t.Run("responses should only be shared by clients with the same credentials", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":"block"}`))
+		cache := NewResponseCache(&CacheOpts{TTLs: map[string]time.Duration{"getBlock": time.Minute}})
+		bearer := func(token string) authFunc {
+			return func(ctx context.Context, request *http.Request, body []byte) error {
+				request.Header.Set("Authorization", "Bearer "+token)
+				return nil
+			}
+		}
+		signer := NewHMACSigner([]byte("secret"), nil)
+
+		clients := []RPCClient{
+			NewClientWithOpts(s.URL, &RPCClientOpts{Cache: cache, CustomHeaders: map[string]string{"Authorization": "Bearer alice"}}),
+			NewClientWithOpts(s.URL, &RPCClientOpts{Cache: cache, CustomHeaders: map[string]string{"Authorization": "Bearer bob"}}),
+			NewClientWithOpts(s.URL, &RPCClientOpts{Cache: cache, Auth: bearer("alice")}),
+			NewClientWithOpts(s.URL, &RPCClientOpts{Cache: cache, Auth: bearer("bob")}),
+			NewClientWithOpts(s.URL, &RPCClientOpts{Cache: cache, Auth: signer}),
+			NewClientWithOpts(s.URL, &RPCClientOpts{Cache: cache, Auth: NewHMACSigner([]byte("other"), nil)}),
+		}
+		for _, client := range clients {
+			client.Call(context.Background(), "getBlock", 1)
+		}
+		check.Equal(len(clients), s.requests())
+
+		// clients with the same static headers or the same provider share the cache
+		NewClientWithOpts(s.URL, &RPCClientOpts{Cache: cache, CustomHeaders: map[string]string{"Authorization": "Bearer alice"}}).Call(context.Background(), "getBlock", 1)
+		NewClientWithOpts(s.URL, &RPCClientOpts{Cache: cache, Auth: signer}).Call(context.Background(), "getBlock", 1)
+		check.Equal(len(clients), s.requests())
+	})
+}
+
+func TestLRUCacheStore(t *testing.T) {
+	check := assert.New(t)
+
+	store := NewLRUCacheStore(2)
+	store.Set("a", []byte(`{"result":"a"}`), time.Minute)
+	store.Set("b", []byte(`{"result":"b"}`), time.Minute)
+
+	// a is now the most recently used entry, so b is evicted
+	_, ok := store.Get("a")
+	check.True(ok)
+	store.Set("c", []byte(`{"result":"c"}`), time.Minute)
+
+	_, ok = store.Get("b")
+	check.False(ok)
+	res, ok := store.Get("a")
+	check.True(ok)
+	check.Equal(`{"result":"a"}`, string(res))
+	_, ok = store.Get("c")
+	check.True(ok)
+
+	store.Set("a", []byte(`{"result":"a2"}`), -time.Second)
+	_, ok = store.Get("a")
+	check.False(ok)
+}
@@ -0,0 +1,86 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// CookieAuth is an AuthProvider that authenticates requests with the credentials of a cookie file,
+// as written by bitcoind, btcd, lnd and similar daemons.
+//
+// The file contains "user:password" and is rewritten with new credentials when the daemon restarts.
+// It is read again if its modification time or size changes, or if the server responds with 401 Unauthorized.
+//
+// e.g.
+//
+//	auth := jsonrpc.NewCookieAuth("/home/bitcoin/.bitcoin/.cookie")
+//	rpcClient := jsonrpc.NewClientWithOpts("http://127.0.0.1:8332", &jsonrpc.RPCClientOpts{Auth: auth})
+//
+// CookieAuth is created using the factory function NewCookieAuth().
+type CookieAuth struct {
+	path string
+
+	mu      sync.Mutex
+	header  string
+	modTime time.Time
+	size    int64
+}
+
+// NewCookieAuth returns a new CookieAuth that reads the cookie file at path.
+// The file is read on the first request, so it does not need to exist yet.
+func NewCookieAuth(path string) *CookieAuth {
+	return &CookieAuth{path: path}
+}
+
+// Authenticate sets the Authorization header of the request to basic auth with the credentials of the cookie file.
+func (c *CookieAuth) Authenticate(ctx context.Context, request *http.Request, body []byte) error {
+	header, err := c.authorization(false)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Authorization", header)
+	return nil
+}
+
+// Refresh reads the cookie file again.
+func (c *CookieAuth) Refresh(ctx context.Context, response *http.Response) error {
+	_, err := c.authorization(true)
+	return err
+}
+
+// authorization returns the value of the Authorization header. The file is read if force is true,
+// if it was not read yet or if it changed since.
+func (c *CookieAuth) authorization(force bool) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return "", fmt.Errorf("read cookie file: %w", err)
+	}
+
+	if !force && c.header != "" && info.ModTime().Equal(c.modTime) && info.Size() == c.size {
+		return c.header, nil
+	}
+
+	content, err := os.ReadFile(c.path)
+	if err != nil {
+		return "", fmt.Errorf("read cookie file: %w", err)
+	}
+
+	credentials := bytes.TrimSpace(content)
+	if bytes.IndexByte(credentials, ':') < 1 {
+		return "", fmt.Errorf("read cookie file %v: expected user:password", c.path)
+	}
+
+	c.header = "Basic " + base64.StdEncoding.EncodeToString(credentials)
+	c.modTime, c.size = info.ModTime(), info.Size()
+	return c.header, nil
+}
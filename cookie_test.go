@@ -0,0 +1,78 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCookieAuth(t *testing.T) {
+	check := assert.New(t)
+
+	var password atomic.Value
+	var requests int64
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		if user, pass, ok := r.BasicAuth(); !ok || user != "__cookie__" || pass != password.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"result":"ok"}`))
+	}))
+	defer s.Close()
+
+	path := filepath.Join(t.TempDir(), ".cookie")
+	modTime := time.Now().Add(-time.Hour)
+	writeCookie := func(pass string, modTime time.Time) {
+		check.Nil(os.WriteFile(path, []byte("__cookie__:"+pass+"\n"), 0600))
+		check.Nil(os.Chtimes(path, modTime, modTime))
+		password.Store(pass)
+	}
+
+	auth := NewCookieAuth(path)
+	rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Auth: auth})
+
+	t.Run("missing cookie file should fail the call", func(t *testing.T) {
+		password.Store("")
+		_, err := rpcClient.Call(context.Background(), "getblockcount")
+		check.ErrorIs(err, os.ErrNotExist)
+		check.EqualValues(0, atomic.LoadInt64(&requests))
+	})
+
+	t.Run("credentials should be read from the cookie file", func(t *testing.T) {
+		writeCookie("aaaa", modTime)
+		res, err := rpcClient.Call(context.Background(), "getblockcount")
+		check.Nil(err)
+		check.Equal("ok", res.Result)
+		check.EqualValues(1, atomic.LoadInt64(&requests))
+	})
+
+	t.Run("changed cookie file should be read again", func(t *testing.T) {
+		writeCookie("bbbb", modTime.Add(time.Minute))
+		_, err := rpcClient.Call(context.Background(), "getblockcount")
+		check.Nil(err)
+		check.EqualValues(2, atomic.LoadInt64(&requests))
+	})
+
+	t.Run("401 should read the cookie file again", func(t *testing.T) {
+		// same size and modification time, so the change is only noticed by the 401
+		writeCookie("cccc", modTime.Add(time.Minute))
+		_, err := rpcClient.Call(context.Background(), "getblockcount")
+		check.Nil(err)
+		check.EqualValues(4, atomic.LoadInt64(&requests))
+	})
+
+	t.Run("invalid cookie file should fail the call", func(t *testing.T) {
+		check.Nil(os.WriteFile(path, []byte("garbage"), 0600))
+		_, err := rpcClient.Call(context.Background(), "getblockcount")
+		check.ErrorContains(err, "expected user:password")
+		check.EqualValues(4, atomic.LoadInt64(&requests))
+	})
+}
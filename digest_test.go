@@ -0,0 +1,127 @@
+package jsonrpc
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func md5Hex(parts ...string) string {
+	sum := md5.Sum([]byte(strings.Join(parts, ":")))
+	return hex.EncodeToString(sum[:])
+}
+
+// requireDigest requires digest auth with MD5 and qop auth for user "user" and password "pass"
+func requireDigest(nonce *atomic.Value) testHandler {
+	return func(w http.ResponseWriter, r *http.Request, _ []byte) {
+		current := nonce.Load().(string)
+
+		if strings.HasPrefix(r.Header.Get("Authorization"), "Basic ") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("password sent in clear text"))
+			return
+		}
+
+		scheme, params, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		p := parseAuthParams(params)
+		if scheme != "Digest" || p["nonce"] != current || p["username"] != "user" ||
+			p["response"] != md5Hex(md5Hex("user", "rpc", "pass"), current, p["nc"], p["cnonce"], "auth", md5Hex(r.Method, p["uri"])) {
+			w.Header().Set("WWW-Authenticate", `Digest realm="rpc", qop="auth,auth-int", nonce="`+current+`", opaque="xyz", algorithm=MD5`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"result":"` + p["nc"] + `"}`))
+	}
+}
+
+func TestDigestAuth(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("challenge should be answered transparently", func(t *testing.T) {
+		var nonce atomic.Value
+		nonce.Store("nonce-1")
+		s := newTestServer(t, requireDigest(&nonce))
+		rpcClient := NewClientWithOpts(s.URL+"/rpc?x=1", &RPCClientOpts{Auth: NewDigestAuth("user", "pass")})
+
+		res, err := rpcClient.Call(context.Background(), "getBlock")
+		check.Nil(err)
+		check.Equal("00000001", res.Result)
+		check.Equal(2, s.requests())
+
+		// the challenge is reused with the next nonce count
+		res, err = rpcClient.Call(context.Background(), "getBlock")
+		check.Nil(err)
+		check.Equal("00000002", res.Result)
+		check.Equal(3, s.requests())
+
+		// a new nonce of the server is answered again
+		nonce.Store("nonce-2")
+		res, err = rpcClient.Call(context.Background(), "getBlock")
+		check.Nil(err)
+		check.Equal("00000001", res.Result)
+		check.Equal(5, s.requests())
+	})
+
+	t.Run("credentials of the endpoint url should be used without sending them", func(t *testing.T) {
+		var nonce atomic.Value
+		nonce.Store("nonce-1")
+		s := newTestServer(t, requireDigest(&nonce))
+		rpcClient := NewClientWithOpts(strings.Replace(s.URL, "http://", "http://user:pass@", 1), &RPCClientOpts{Auth: NewDigestAuth("", "")})
+
+		res, err := rpcClient.Call(context.Background(), "getBlock")
+		check.Nil(err)
+		check.Equal("00000001", res.Result)
+		check.Equal(2, s.requests())
+	})
+
+	t.Run("wrong password should fail with 401", func(t *testing.T) {
+		var nonce atomic.Value
+		nonce.Store("nonce-1")
+		s := newTestServer(t, requireDigest(&nonce))
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Auth: NewDigestAuth("user", "wrong")})
+
+		_, err := rpcClient.Call(context.Background(), "getBlock")
+		var httpErr *HTTPError
+		check.ErrorAs(err, &httpErr)
+		check.Equal(http.StatusUnauthorized, httpErr.Code)
+		check.Equal(2, s.requests())
+	})
+
+	t.Run("401 without digest challenge should fail", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusUnauthorized, ""))
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Auth: NewDigestAuth("user", "pass")})
+
+		_, err := rpcClient.Call(context.Background(), "getBlock")
+		check.ErrorIs(err, errNoDigestChallenge)
+	})
+
+	t.Run("response should match the RFC 2617 example", func(t *testing.T) {
+		header := http.Header{}
+		header.Add("WWW-Authenticate", `Basic realm="other"`)
+		header.Add("WWW-Authenticate", `Digest realm="testrealm@host.com", qop="auth,auth-int", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`)
+		challenge, err := parseDigestChallenge(header)
+		check.Nil(err)
+
+		request, _ := http.NewRequest("GET", "http://www.nowhere.org/dir/index.html", nil)
+		authorization, err := challenge.authorization("Mufasa", "Circle Of Life", 1, func() (string, error) { return "0a4f113b", nil }, request, nil)
+		check.Nil(err)
+
+		p := parseAuthParams(strings.TrimPrefix(authorization, "Digest "))
+		check.Equal("6629fae49393a05397450978507c4ef1", p["response"])
+		check.Equal("/dir/index.html", p["uri"])
+		check.Equal("00000001", p["nc"])
+		check.Equal("auth", p["qop"])
+		check.Equal("5ccc069c403ebaf9f0171e9517f40e41", p["opaque"])
+	})
+
+	t.Run("auth params should be parsed", func(t *testing.T) {
+		p := parseAuthParams(`Realm="a \"b\", c", qop=auth , stale=TRUE,algorithm="SHA-256-sess"`)
+		check.Equal(map[string]string{"realm": `a "b", c`, "qop": "auth", "stale": "TRUE", "algorithm": "SHA-256-sess"}, p)
+	})
+}
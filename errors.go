@@ -0,0 +1,127 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+)
+
+// maxHTTPErrorBody is the maximum number of bytes of the response body kept in an HTTPError.
+const maxHTTPErrorBody = 4 << 10
+
+// ErrorClass is the classification of an error returned by the client (see ClassifyError()).
+type ErrorClass int
+
+const (
+	// ErrorClassNone is the class of a nil error.
+	ErrorClassNone ErrorClass = iota
+	// ErrorClassUnknown is the class of errors that fit no other class, e.g. a request that could not be encoded.
+	ErrorClassUnknown
+	// ErrorClassNetwork is the class of errors that occurred while sending the request or reading the response.
+	ErrorClassNetwork
+	// ErrorClassHTTP is the class of errors caused by an http error status code (see HTTPError).
+	ErrorClassHTTP
+	// ErrorClassDecode is the class of errors caused by a response body that is no valid JSON-RPC response.
+	ErrorClassDecode
+	// ErrorClassProtocol is the class of errors caused by a response that violates the JSON-RPC protocol, e.g. a missing response.
+	ErrorClassProtocol
+	// ErrorClassRPC is the class of JSON-RPC errors returned by the server (see RPCError).
+	ErrorClassRPC
+	// ErrorClassTimeout is the class of calls that exceeded their deadline.
+	ErrorClassTimeout
+	// ErrorClassCanceled is the class of calls whose context was canceled.
+	ErrorClassCanceled
+	// ErrorClassRejected is the class of calls rejected by the client side rate limit or bulkhead.
+	ErrorClassRejected
+)
+
+var errorClassNames = map[ErrorClass]string{
+	ErrorClassNone:     "none",
+	ErrorClassUnknown:  "unknown",
+	ErrorClassNetwork:  "network",
+	ErrorClassHTTP:     "http",
+	ErrorClassDecode:   "decode",
+	ErrorClassProtocol: "protocol",
+	ErrorClassRPC:      "rpc",
+	ErrorClassTimeout:  "timeout",
+	ErrorClassCanceled: "canceled",
+	ErrorClassRejected: "rejected",
+}
+
+// String returns the name of the class, e.g. "network".
+func (c ErrorClass) String() string {
+	if name, ok := errorClassNames[c]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ClassifyError returns the class of an error returned by the client.
+//
+// e.g.
+//
+//	_, err := rpcClient.Call(ctx, "getBlock", 123)
+//	switch jsonrpc.ClassifyError(err) {
+//	case jsonrpc.ErrorClassNetwork, jsonrpc.ErrorClassTimeout:
+//		// retry
+//	case jsonrpc.ErrorClassHTTP:
+//		// inspect the *HTTPError
+//	}
+//
+// An HTTPError is always of class ErrorClassHTTP, regardless of its underlying error.
+func ClassifyError(err error) ErrorClass {
+	var rpcErr *RPCError
+	var httpErr *HTTPError
+	var timeoutErr *TimeoutError
+	var classified *classifiedError
+	var netErr net.Error
+	var urlErr *url.Error
+
+	switch {
+	case err == nil:
+		return ErrorClassNone
+	case errors.As(err, &rpcErr):
+		return ErrorClassRPC
+	case errors.As(err, &httpErr):
+		return ErrorClassHTTP
+	case errors.As(err, &timeoutErr), errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	case errors.Is(err, ErrRateLimited), errors.Is(err, ErrBatchExceedsBurst), errors.Is(err, ErrBulkheadFull):
+		return ErrorClassRejected
+	case errors.As(err, &classified):
+		return classified.class
+	case errors.As(err, &netErr), errors.As(err, &urlErr):
+		return ErrorClassNetwork
+	default:
+		return ErrorClassUnknown
+	}
+}
+
+// classifiedError marks err with the class of the step of the call in which it occurred.
+type classifiedError struct {
+	class ErrorClass
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+// errResponseMissing is returned if the server sent no response for a request.
+var errResponseMissing error = &classifiedError{ErrorClassProtocol, errors.New("rpc response missing")}
+
+// bodySnippet returns the beginning of a response body for an HTTPError.
+func bodySnippet(body []byte) []byte {
+	if len(body) > maxHTTPErrorBody {
+		body = body[:maxHTTPErrorBody]
+	}
+
+	return append([]byte(nil), body...)
+}
@@ -0,0 +1,86 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPError(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("http error should carry headers, body and underlying error", func(t *testing.T) {
+		s := newTestServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+			w.Header().Set("X-Served-By", "lb-1")
+			respond(http.StatusBadGateway, "<html>502 Bad Gateway</html>")(w, r, body)
+		})
+
+		_, err := NewClient(s.URL).Call(context.Background(), "getBlock")
+
+		var httpErr *HTTPError
+		check.True(errors.As(err, &httpErr))
+		check.Equal(http.StatusBadGateway, httpErr.Code)
+		check.Equal("lb-1", httpErr.Header.Get("X-Served-By"))
+		check.Equal("<html>502 Bad Gateway</html>", string(httpErr.Body))
+
+		var syntaxErr *json.SyntaxError
+		check.True(errors.As(err, &syntaxErr))
+	})
+
+	t.Run("body should be truncated", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusInternalServerError, strings.Repeat("x", 10000)))
+
+		_, err := NewClient(s.URL).CallBatch(context.Background(), RPCRequests{NewRequest("a")})
+
+		var httpErr *HTTPError
+		check.True(errors.As(err, &httpErr))
+		check.Len(httpErr.Body, 4096)
+	})
+}
+
+func TestClassifyError(t *testing.T) {
+	check := assert.New(t)
+
+	call := func(endpoint string, opts *RPCClientOpts) error {
+		return NewClientWithOpts(endpoint, opts).CallFor(context.Background(), nil, "getBlock")
+	}
+
+	t.Run("errors should be classified", func(t *testing.T) {
+		check.Equal(ErrorClassNone, ClassifyError(nil))
+		check.Equal(ErrorClassNetwork, ClassifyError(call("http://127.0.0.1:1", nil)))
+		check.Equal(ErrorClassHTTP, ClassifyError(call(newTestServer(t, respond(http.StatusBadGateway, "")).URL, nil)))
+		check.Equal(ErrorClassHTTP, ClassifyError(call(newTestServer(t, respond(http.StatusInternalServerError, `{"error":{"code":1,"message":"a"}}`)).URL, nil)))
+		check.Equal(ErrorClassDecode, ClassifyError(call(newTestServer(t, respond(http.StatusOK, "<html>")).URL, nil)))
+		check.Equal(ErrorClassDecode, ClassifyError(call(newTestServer(t, respond(http.StatusOK, `{"result":1,"unknown":2}`)).URL, nil)))
+		check.Equal(ErrorClassProtocol, ClassifyError(call(newTestServer(t, respond(http.StatusOK, "null")).URL, nil)))
+		check.Equal(ErrorClassRPC, ClassifyError(call(newTestServer(t, respond(http.StatusOK, `{"error":{"code":1,"message":"a"}}`)).URL, nil)))
+
+		var canceled int32
+		slowServer := newTestServer(t, slow(time.Second, &canceled))
+		check.Equal(ErrorClassTimeout, ClassifyError(call(slowServer.URL, &RPCClientOpts{Timeout: 10 * time.Millisecond})))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := NewClient(slowServer.URL).Call(ctx, "getBlock")
+		check.Equal(ErrorClassCanceled, ClassifyError(err))
+
+		limited := &RPCClientOpts{RateLimit: &RateLimitOpts{RateLimit: RateLimit{Rate: 0.001, Burst: 1}, FailFast: true}}
+		_, err = NewClientWithOpts(slowServer.URL, limited).CallBatch(context.Background(), RPCRequests{NewRequest("a"), NewRequest("b")})
+		check.Equal(ErrorClassRejected, ClassifyError(err))
+
+		_, err = NewClient(slowServer.URL).Call(context.Background(), "invalidParams", make(chan int))
+		check.Equal(ErrorClassUnknown, ClassifyError(err))
+	})
+
+	t.Run("classes should have names", func(t *testing.T) {
+		check.Equal("network", ErrorClassNetwork.String())
+		check.Equal("protocol", ErrorClassProtocol.String())
+		check.Equal("unknown", ErrorClass(100).String())
+	})
+}
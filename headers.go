@@ -0,0 +1,123 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// HeaderProvider returns headers that are set on a single request, computed from the context of the call.
+// It is called once for every call, its headers are sent with every http request of the call, including a retry.
+// Every hedged request of a BalancedClient is a call of its own.
+//
+// The headers overwrite RPCClientOpts.CustomHeaders, headers of WithHeaders() overwrite them.
+// Cached and deduplicated calls only share a response if their headers are equal, except for the headers
+// listed in RPCClientOpts.KeyIgnoreHeaders.
+// If an error is returned, the call fails without sending a request.
+//
+// e.g.
+//
+//	HeaderProvider: func(ctx context.Context) (map[string]string, error) {
+//		return map[string]string{"X-Tenant-Id": tenantFrom(ctx)}, nil
+//	}
+type HeaderProvider func(ctx context.Context) (map[string]string, error)
+
+type headersKey struct{}
+
+type providedHeadersKey struct{}
+
+// providedHeaders holds the result of the HeaderProvider of client for a call.
+type providedHeaders struct {
+	client  *rpcClient
+	headers map[string]string
+	err     error
+}
+
+// WithHeaders returns a copy of ctx with headers that are set on every request of calls made with the context.
+// They are merged with headers of a parent context, the given ones take precedence.
+//
+// As with RPCClientOpts.CustomHeaders, the header "Host" sets the host of the request.
+func WithHeaders(ctx context.Context, headers map[string]string) context.Context {
+	merged := make(map[string]string)
+	for k, v := range headersFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range headers {
+		merged[k] = v
+	}
+
+	return context.WithValue(ctx, headersKey{}, merged)
+}
+
+func headersFromContext(ctx context.Context) map[string]string {
+	headers, _ := ctx.Value(headersKey{}).(map[string]string)
+	return headers
+}
+
+// setHeaders sets the headers on the request.
+func setHeaders(request *http.Request, headers map[string]string) {
+	for k, v := range headers {
+		// check if header is "Host" since this will be set on the request struct itself
+		if k == "Host" {
+			request.Host = v
+		} else {
+			request.Header.Set(k, v)
+		}
+	}
+}
+
+// withProvidedHeaders calls the HeaderProvider and stores its result in ctx,
+// so that the key of a cached or deduplicated call and its request use the same headers.
+func (client *rpcClient) withProvidedHeaders(ctx context.Context) context.Context {
+	if client.headerProvider == nil {
+		return ctx
+	}
+
+	headers, err := client.headerProvider(ctx)
+	return context.WithValue(ctx, providedHeadersKey{}, &providedHeaders{client, headers, err})
+}
+
+// providedHeaders returns the headers of the HeaderProvider for a call, either stored by withProvidedHeaders() or
+// by calling the HeaderProvider.
+func (client *rpcClient) providedHeaders(ctx context.Context) (map[string]string, error) {
+	if client.headerProvider == nil {
+		return nil, nil
+	}
+
+	if provided, ok := ctx.Value(providedHeadersKey{}).(*providedHeaders); ok && provided.client == client {
+		return provided.headers, provided.err
+	}
+
+	return client.headerProvider(ctx)
+}
+
+// callHeaders returns the headers of the HeaderProvider and of WithHeaders() for a call as canonical json,
+// empty if there are none. Calls with different per call headers must not share a request or a response.
+// Headers of RPCClientOpts.KeyIgnoreHeaders are left out.
+func (client *rpcClient) callHeaders(ctx context.Context) (string, error) {
+	provided, err := client.providedHeaders(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	headers := make(map[string]string)
+	for k, v := range provided {
+		headers[k] = v
+	}
+	for k, v := range headersFromContext(ctx) {
+		headers[k] = v
+	}
+	for k := range headers {
+		if client.keyIgnoreHeaders[http.CanonicalHeaderKey(k)] {
+			delete(headers, k)
+		}
+	}
+
+	if len(headers) == 0 {
+		return "", nil
+	}
+
+	// map keys are encoded in sorted order
+	raw, err := json.Marshal(headers)
+	return string(raw), err
+}
@@ -0,0 +1,126 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tenantKey struct{}
+
+func TestHeaderProvider(t *testing.T) {
+	check := assert.New(t)
+
+	hosts := make(chan string, 10)
+	headers := make(chan http.Header, 10)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts <- r.Host
+		headers <- r.Header
+		w.Write([]byte(`{"result":"ok"}`))
+	}))
+	defer s.Close()
+
+	t.Run("headers should be computed per request", func(t *testing.T) {
+		calls := 0
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{
+			CustomHeaders: map[string]string{"X-Static": "static", "X-Tenant": "default"},
+			HeaderProvider: func(ctx context.Context) (map[string]string, error) {
+				calls++
+				tenant, _ := ctx.Value(tenantKey{}).(string)
+				return map[string]string{"X-Tenant": tenant}, nil
+			},
+		})
+
+		rpcClient.Call(context.WithValue(context.Background(), tenantKey{}, "a"), "getBlock")
+		header := <-headers
+		<-hosts
+		check.Equal("a", header.Get("X-Tenant"))
+		check.Equal("static", header.Get("X-Static"))
+
+		rpcClient.CallBatch(context.WithValue(context.Background(), tenantKey{}, "b"), RPCRequests{NewRequest("getBlock")})
+		header = <-headers
+		<-hosts
+		check.Equal("b", header.Get("X-Tenant"))
+		check.Equal(2, calls)
+	})
+
+	t.Run("context headers should overwrite all other headers", func(t *testing.T) {
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{
+			CustomHeaders: map[string]string{"X-Request-Id": "static", "Host": "static-host.com"},
+			HeaderProvider: func(ctx context.Context) (map[string]string, error) {
+				return map[string]string{"X-Request-Id": "provider", "X-Provider": "provider"}, nil
+			},
+		})
+
+		ctx := WithHeaders(context.Background(), map[string]string{"X-Request-Id": "parent", "X-Parent": "parent"})
+		ctx = WithHeaders(ctx, map[string]string{"X-Request-Id": "call", "Host": "call-host.com"})
+		rpcClient.Call(ctx, "getBlock")
+
+		header := <-headers
+		check.Equal("call", header.Get("X-Request-Id"))
+		check.Equal("parent", header.Get("X-Parent"))
+		check.Equal("provider", header.Get("X-Provider"))
+		check.Equal("call-host.com", <-hosts)
+	})
+
+	t.Run("provider should be called once per deduplicated or cached call", func(t *testing.T) {
+		s := newTestServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+			time.Sleep(100 * time.Millisecond)
+			w.Write([]byte(`{"result":"ok"}`))
+		})
+
+		var calls int64
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{
+			HeaderProvider: func(ctx context.Context) (map[string]string, error) {
+				id := atomic.AddInt64(&calls, 1)
+				return map[string]string{"X-Request-Id": strconv.FormatInt(id, 10), "X-Tenant": "a"}, nil
+			},
+			DeduplicateMethods: []string{"getBlock"},
+			Cache:              NewResponseCache(&CacheOpts{TTLs: map[string]time.Duration{"getBalance": time.Minute}}),
+			KeyIgnoreHeaders:   []string{"x-request-id"},
+		})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := rpcClient.Call(context.Background(), "getBlock", 1)
+				check.Nil(err)
+			}()
+		}
+		wg.Wait()
+		check.Equal(1, s.requests())
+		check.EqualValues(10, atomic.LoadInt64(&calls))
+		// the shared request carries the headers of the call that started it
+		check.NotEmpty((<-s.headers).Get("X-Request-Id"))
+
+		for i := 0; i < 2; i++ {
+			_, err := rpcClient.Call(context.Background(), "getBalance", 1)
+			check.Nil(err)
+		}
+		check.Equal(2, s.requests())
+		check.EqualValues(12, atomic.LoadInt64(&calls))
+	})
+
+	t.Run("provider error should fail the call without request", func(t *testing.T) {
+		providerErr := errors.New("token unavailable")
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{
+			HeaderProvider: func(ctx context.Context) (map[string]string, error) {
+				return nil, providerErr
+			},
+		})
+
+		_, err := rpcClient.Call(context.Background(), "getBlock")
+		check.ErrorIs(err, providerErr)
+		check.Len(headers, 0)
+	})
+}
@@ -0,0 +1,233 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// EndpointStatus is a snapshot of the state of a single endpoint of a BalancedClient.
+//
+// Healthy: false if the endpoint failed the last UnhealthyThreshold health checks.
+//
+// Outstanding: number of requests currently in flight.
+//
+// LastCheck: time of the last health check, zero if no check was done yet.
+//
+// LastError: error of the last health check, nil if it was successful.
+type EndpointStatus struct {
+	URL                  string
+	Healthy              bool
+	Outstanding          int64
+	LastCheck            time.Time
+	LastError            error
+	ConsecutiveFailures  int
+	ConsecutiveSuccesses int
+}
+
+type endpointStatus struct {
+	mu                   sync.Mutex
+	healthy              bool
+	lastCheck            time.Time
+	lastError            error
+	consecutiveFailures  int
+	consecutiveSuccesses int
+}
+
+func (s *endpointStatus) isHealthy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.healthy
+}
+
+// record stores the result of a health check and updates the health state according to the thresholds.
+func (s *endpointStatus) record(err error, unhealthyThreshold int, healthyThreshold int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastCheck = time.Now()
+	s.lastError = err
+
+	if err != nil {
+		s.consecutiveFailures++
+		s.consecutiveSuccesses = 0
+		if s.consecutiveFailures >= unhealthyThreshold {
+			s.healthy = false
+		}
+		return
+	}
+
+	s.consecutiveSuccesses++
+	s.consecutiveFailures = 0
+	if s.consecutiveSuccesses >= healthyThreshold {
+		s.healthy = true
+	}
+}
+
+type healthCheck struct {
+	method             string
+	interval           time.Duration
+	timeout            time.Duration
+	unhealthyThreshold int
+	healthyThreshold   int
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// startHealthCheck starts the background health checks if they are configured.
+func (b *BalancedClient) startHealthCheck(opts *BalancedClientOpts) {
+	b.health = healthCheck{
+		method:             opts.HealthCheckMethod,
+		interval:           opts.HealthCheckInterval,
+		timeout:            opts.HealthCheckTimeout,
+		unhealthyThreshold: opts.UnhealthyThreshold,
+		healthyThreshold:   opts.HealthyThreshold,
+	}
+
+	if b.health.timeout <= 0 {
+		b.health.timeout = b.health.interval
+	}
+	if b.health.unhealthyThreshold < 1 {
+		b.health.unhealthyThreshold = 1
+	}
+	if b.health.healthyThreshold < 1 {
+		b.health.healthyThreshold = 1
+	}
+
+	if b.health.method == "" || b.health.interval <= 0 {
+		return
+	}
+
+	b.health.stop = make(chan struct{})
+	b.health.done = make(chan struct{})
+
+	go func() {
+		defer close(b.health.done)
+
+		ticker := time.NewTicker(b.health.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-b.health.stop:
+				return
+			case <-ticker.C:
+				b.CheckHealth(context.Background())
+			}
+		}
+	}()
+}
+
+// CheckHealth probes all endpoints once with the configured HealthCheckMethod and updates their health state.
+//
+// It is called periodically if HealthCheckInterval is set, but can also be used to trigger a check manually.
+// It does nothing if no HealthCheckMethod is configured.
+func (b *BalancedClient) CheckHealth(ctx context.Context) {
+	if b.health.method == "" {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, e := range b.endpoints {
+		wg.Add(1)
+		go func(e *endpoint) {
+			defer wg.Done()
+
+			probeCtx := ctx
+			if b.health.timeout > 0 {
+				var cancel context.CancelFunc
+				probeCtx, cancel = context.WithTimeout(probeCtx, b.health.timeout)
+				defer cancel()
+			}
+
+			res, err := e.client.probe(probeCtx, b.health.method)
+			if err == nil && res.Error != nil {
+				err = res.Error
+			}
+			e.status.record(err, b.health.unhealthyThreshold, b.health.healthyThreshold)
+		}(e)
+	}
+	wg.Wait()
+}
+
+// probe sends a request directly to the endpoint. Unlike Call(), it bypasses the response cache, deduplication,
+// rate limits, the endpoint pause, the bulkhead and the adaptive limiter, so that a health check reflects the
+// current state of the endpoint and neither waits for nor consumes the quota of regular calls.
+func (client *rpcClient) probe(ctx context.Context, method string) (*RPCResponse, error) {
+	request := &RPCRequest{
+		ID:      client.defaultRequestID,
+		Method:  method,
+		JSONRPC: jsonrpcVersion,
+	}
+
+	ctx, span := client.startSpan(ctx, method)
+	start := time.Now()
+	ex := &exchange{}
+
+	client.metrics.requestStarted()
+	rpcResponse, err := client.send(ctx, request, ex)
+	client.metrics.requestFinished()
+
+	client.observeCall(ctx, span, request, rpcResponse, ex, time.Since(start), err)
+	return rpcResponse, err
+}
+
+// Endpoints returns the current status of all endpoints in the order they were provided.
+func (b *BalancedClient) Endpoints() []EndpointStatus {
+	res := make([]EndpointStatus, 0, len(b.endpoints))
+	for _, e := range b.endpoints {
+		e.status.mu.Lock()
+		res = append(res, EndpointStatus{
+			URL:                  e.url,
+			Healthy:              e.status.healthy,
+			Outstanding:          e.outstandingRequests(),
+			LastCheck:            e.status.lastCheck,
+			LastError:            e.status.lastError,
+			ConsecutiveFailures:  e.status.consecutiveFailures,
+			ConsecutiveSuccesses: e.status.consecutiveSuccesses,
+		})
+		e.status.mu.Unlock()
+	}
+
+	return res
+}
+
+// Close stops the background health checks. The client can still be used afterwards.
+func (b *BalancedClient) Close() error {
+	if b.health.stop == nil {
+		return nil
+	}
+
+	b.health.closeOnce.Do(func() {
+		close(b.health.stop)
+	})
+	<-b.health.done
+
+	return nil
+}
+
+// shouldFailover returns true if a call that failed with err may be repeated on another endpoint.
+//
+// Network errors, undecodable responses and HTTP errors with status code 429 or >= 500 are eligible,
+// RPCErrors and cancellations of the caller's context are not.
+func shouldFailover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return false
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code >= 500 || httpErr.Code == 429
+	}
+
+	return true
+}
@@ -0,0 +1,195 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// toggle answers with status 200 while healthy is 1 and with status 500 otherwise
+func toggle(healthy *int32) testHandler {
+	return func(w http.ResponseWriter, r *http.Request, body []byte) {
+		if atomic.LoadInt32(healthy) == 0 {
+			respond(http.StatusInternalServerError, `{"error":{"code":-32000,"message":"down"}}`)(w, r, body)
+			return
+		}
+		respond(http.StatusOK, `{"result":"ok"}`)(w, r, body)
+	}
+}
+
+func TestBalancedClientFailover(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("idempotent calls should fail over to the next endpoint", func(t *testing.T) {
+		var healthy1, healthy2 int32 = 0, 1
+		s1 := newTestServer(t, toggle(&healthy1))
+		s2 := newTestServer(t, toggle(&healthy2))
+
+		client := NewBalancedClient([]Endpoint{{URL: s1.URL}, {URL: s2.URL}}, &BalancedClientOpts{
+			Strategy:          StrategyFailover,
+			IdempotentMethods: []string{"getBlock"},
+		})
+
+		res, err := client.Call(context.Background(), "getBlock", 1)
+		check.Nil(err)
+		check.Equal("ok", res.Result)
+		check.Equal(1, s1.requests())
+		check.Equal(1, s2.requests())
+
+		var out string
+		err = client.CallFor(context.Background(), &out, "getBlock", 1)
+		check.Nil(err)
+		check.Equal("ok", out)
+	})
+
+	t.Run("non idempotent calls should not fail over", func(t *testing.T) {
+		var healthy1, healthy2 int32 = 0, 1
+		s1 := newTestServer(t, toggle(&healthy1))
+		s2 := newTestServer(t, toggle(&healthy2))
+
+		client := NewBalancedClient([]Endpoint{{URL: s1.URL}, {URL: s2.URL}}, &BalancedClientOpts{
+			Strategy:          StrategyFailover,
+			IdempotentMethods: []string{"getBlock"},
+		})
+
+		_, err := client.Call(context.Background(), "sendTransaction", 1)
+		check.NotNil(err)
+		check.Equal(0, s2.requests())
+
+		_, err = client.CallBatch(context.Background(), RPCRequests{
+			NewRequest("getBlock", 1),
+			NewRequest("sendTransaction", 1),
+		})
+		check.NotNil(err)
+		check.Equal(0, s2.requests())
+	})
+
+	t.Run("rpc errors should not fail over", func(t *testing.T) {
+		s1 := newTestServer(t, respond(http.StatusOK, `{"error":{"code":123,"message":"invalid block"}}`))
+		s2 := newTestServer(t, respond(http.StatusOK, `{"result":"ok"}`))
+
+		client := NewBalancedClient([]Endpoint{{URL: s1.URL}, {URL: s2.URL}}, &BalancedClientOpts{
+			Strategy:          StrategyFailover,
+			IdempotentMethods: []string{"getBlock"},
+		})
+
+		var out string
+		err := client.CallFor(context.Background(), &out, "getBlock", 1)
+		check.NotNil(err)
+		check.Equal(0, s2.requests())
+	})
+}
+
+func TestBalancedClientHealthCheck(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("unhealthy endpoints should be skipped and restored", func(t *testing.T) {
+		var healthy1, healthy2 int32 = 1, 1
+		s1 := newTestServer(t, toggle(&healthy1))
+		s2 := newTestServer(t, toggle(&healthy2))
+
+		client := NewBalancedClient([]Endpoint{{URL: s1.URL}, {URL: s2.URL}}, &BalancedClientOpts{
+			Strategy:          StrategyFailover,
+			HealthCheckMethod: "health",
+		})
+
+		atomic.StoreInt32(&healthy1, 0)
+		client.CheckHealth(context.Background())
+
+		status := client.Endpoints()
+		check.Len(status, 2)
+		check.Equal(s1.URL, status[0].URL)
+		check.False(status[0].Healthy)
+		check.NotNil(status[0].LastError)
+		check.Equal(1, status[0].ConsecutiveFailures)
+		check.True(status[1].Healthy)
+		check.False(status[1].LastCheck.IsZero())
+
+		before := s1.requests()
+		_, err := client.Call(context.Background(), "something")
+		check.Nil(err)
+		check.Equal(before, s1.requests())
+
+		atomic.StoreInt32(&healthy1, 1)
+		client.CheckHealth(context.Background())
+		check.True(client.Endpoints()[0].Healthy)
+
+		before = s1.requests()
+		_, err = client.Call(context.Background(), "something")
+		check.Nil(err)
+		check.Equal(before+1, s1.requests())
+	})
+
+	t.Run("thresholds should delay state changes", func(t *testing.T) {
+		var healthy int32 = 0
+		s := newTestServer(t, toggle(&healthy))
+
+		client := NewBalancedClient([]Endpoint{{URL: s.URL}}, &BalancedClientOpts{
+			HealthCheckMethod:  "health",
+			UnhealthyThreshold: 2,
+			HealthyThreshold:   2,
+		})
+
+		client.CheckHealth(context.Background())
+		check.True(client.Endpoints()[0].Healthy)
+		client.CheckHealth(context.Background())
+		check.False(client.Endpoints()[0].Healthy)
+
+		atomic.StoreInt32(&healthy, 1)
+		client.CheckHealth(context.Background())
+		check.False(client.Endpoints()[0].Healthy)
+		client.CheckHealth(context.Background())
+		check.True(client.Endpoints()[0].Healthy)
+	})
+
+	t.Run("background health checks should run until closed", func(t *testing.T) {
+		var healthy int32 = 0
+		s := newTestServer(t, toggle(&healthy))
+
+		client := NewBalancedClient([]Endpoint{{URL: s.URL}}, &BalancedClientOpts{
+			HealthCheckMethod:   "health",
+			HealthCheckInterval: 5 * time.Millisecond,
+		})
+
+		check.Eventually(func() bool {
+			return !client.Endpoints()[0].Healthy
+		}, time.Second, 5*time.Millisecond)
+
+		check.Nil(client.Close())
+		check.Nil(client.Close())
+
+		count := s.requests()
+		time.Sleep(20 * time.Millisecond)
+		check.Equal(count, s.requests())
+	})
+
+	t.Run("health checks should bypass cache and rate limit", func(t *testing.T) {
+		var healthy int32 = 1
+		s := newTestServer(t, toggle(&healthy))
+
+		client := NewBalancedClient([]Endpoint{{URL: s.URL}}, &BalancedClientOpts{
+			HealthCheckMethod: "health",
+			ClientOpts: &RPCClientOpts{
+				Cache:     NewResponseCache(&CacheOpts{TTLs: map[string]time.Duration{"health": time.Hour}}),
+				RateLimit: &RateLimitOpts{RateLimit: RateLimit{Rate: 0.001, Burst: 1}, FailFast: true},
+			},
+		})
+
+		client.CheckHealth(context.Background())
+		check.True(client.Endpoints()[0].Healthy)
+
+		atomic.StoreInt32(&healthy, 0)
+		client.CheckHealth(context.Background())
+		check.False(client.Endpoints()[0].Healthy)
+		check.Equal(2, s.requests())
+
+		// the probes did not consume the single token of the rate limit
+		atomic.StoreInt32(&healthy, 1)
+		_, err := client.Call(context.Background(), "getBlock")
+		check.Nil(err)
+	})
+}
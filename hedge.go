@@ -0,0 +1,214 @@
+package jsonrpc
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// batchMethod is the method name under which the latencies of batch requests are tracked.
+	batchMethod = ""
+
+	// latencyWindowSize is the number of latencies that are kept per method to calculate the hedge delay.
+	latencyWindowSize = 128
+
+	// minLatencySamples is the number of latencies that must be observed before HedgePercentile is used.
+	minLatencySamples = 16
+)
+
+type hedging struct {
+	delay      time.Duration
+	percentile float64
+	maxHedges  int
+
+	mu        sync.Mutex
+	latencies map[string]*latencyWindow
+}
+
+func newHedging(opts *BalancedClientOpts) *hedging {
+	h := &hedging{
+		delay:      opts.HedgeDelay,
+		percentile: opts.HedgePercentile,
+		maxHedges:  opts.MaxHedges,
+		latencies:  make(map[string]*latencyWindow),
+	}
+
+	if h.maxHedges < 1 {
+		h.maxHedges = 1
+	}
+
+	return h
+}
+
+func (h *hedging) enabled() bool {
+	return h.delay > 0 || (h.percentile > 0 && h.percentile < 1)
+}
+
+// delayFor returns the time to wait for a response of method before a hedged request is sent.
+// It returns false if the call should not be hedged, because not enough latencies were observed
+// for HedgePercentile and no HedgeDelay is set.
+func (h *hedging) delayFor(method string) (time.Duration, bool) {
+	if h.percentile <= 0 || h.percentile >= 1 {
+		return h.delay, h.delay > 0
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	window := h.latencies[method]
+	if window == nil || window.count < minLatencySamples {
+		return h.delay, h.delay > 0
+	}
+
+	return window.percentile(h.percentile), true
+}
+
+// observe records the latency of a completed request.
+func (h *hedging) observe(method string, latency time.Duration) {
+	if h.percentile <= 0 || h.percentile >= 1 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	window := h.latencies[method]
+	if window == nil {
+		window = &latencyWindow{}
+		h.latencies[method] = window
+	}
+	window.add(latency)
+}
+
+// latencyWindow is a ring buffer of the most recent latencies.
+type latencyWindow struct {
+	samples [latencyWindowSize]time.Duration
+	next    int
+	count   int
+}
+
+func (w *latencyWindow) add(latency time.Duration) {
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % latencyWindowSize
+	if w.count < latencyWindowSize {
+		w.count++
+	}
+}
+
+func (w *latencyWindow) percentile(p float64) time.Duration {
+	sorted := make([]time.Duration, w.count)
+	copy(sorted, w.samples[:w.count])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return sorted[int(p*float64(w.count-1))]
+}
+
+type hedgeResult struct {
+	res interface{}
+	err error
+}
+
+// runHedged sends the call to one endpoint and starts additional requests on other endpoints
+// whenever the hedge delay passes without a response or a request fails with an error eligible for failover.
+// It returns the first successful response and cancels all other requests.
+// A response with an RPCError is only returned if no other request succeeds.
+func (b *BalancedClient) runHedged(ctx context.Context, method string, candidates []*endpoint, call endpointCall) (interface{}, error) {
+	hedgeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, len(candidates)+b.hedge.maxHedges)
+	remaining := candidates
+	pending := 0
+
+	// launch sends the request to the next endpoint, retryErr is the error of the failed attempt it replaces (nil for hedges)
+	retries := 0
+	launch := func(retryErr error) bool {
+		e := b.pick(remaining)
+		if e == nil {
+			// every endpoint was already tried, send the request to the same ones again
+			e = b.pick(candidates)
+			if e == nil {
+				return false
+			}
+		}
+		remaining = without(remaining, e)
+		pending++
+
+		if retryErr != nil {
+			retries++
+			b.hooks.retry(ctx, method, retries, redactURL(e.url), retryErr)
+		}
+
+		go func() {
+			start := time.Now()
+			res, err := b.invoke(hedgeCtx, e, call)
+			// requests that were canceled because another one won did not complete
+			if hedgeCtx.Err() == nil {
+				b.hedge.observe(method, time.Since(start))
+			}
+			results <- hedgeResult{res, err}
+		}()
+		return true
+	}
+
+	if !launch(nil) {
+		return nil, ErrNoEndpoints
+	}
+
+	// without a hedge delay the timer channel stays nil and only failovers are launched
+	var timer *time.Timer
+	var timeout <-chan time.Time
+	delay, hedge := b.hedge.delayFor(method)
+	if hedge {
+		timer = time.NewTimer(delay)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	hedges := 0
+	var last, rejected hedgeResult
+	for pending > 0 {
+		select {
+		case <-timeout:
+			if hedges < b.hedge.maxHedges && launch(nil) {
+				hedges++
+				timer.Reset(delay)
+			}
+		case result := <-results:
+			pending--
+			if result.err == nil && hasRPCError(result.res) {
+				// another endpoint may still answer successfully
+				rejected = result
+				continue
+			}
+			if result.err == nil || !shouldFailover(ctx, result.err) {
+				return result.res, result.err
+			}
+			last = result
+
+			// fail over immediately instead of waiting for the next hedge
+			if len(remaining) > 0 {
+				launch(result.err)
+			}
+		}
+	}
+
+	if rejected.res != nil {
+		return rejected.res, nil
+	}
+
+	return last.res, last.err
+}
+
+// hasRPCError returns true if res is an *RPCResponse or RPCResponses that contains an RPCError.
+func hasRPCError(res interface{}) bool {
+	switch res := res.(type) {
+	case *RPCResponse:
+		return res != nil && res.Error != nil
+	case RPCResponses:
+		return res.HasError()
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slow answers after delay or stops as soon as the request is canceled
+func slow(delay time.Duration, canceled *int32) testHandler {
+	return func(w http.ResponseWriter, r *http.Request, _ []byte) {
+		select {
+		case <-time.After(delay):
+			w.Write([]byte(`{"result":"slow"}`))
+		case <-r.Context().Done():
+			atomic.AddInt32(canceled, 1)
+		}
+	}
+}
+
+func TestBalancedClientHedging(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("slow idempotent calls should be hedged", func(t *testing.T) {
+		var canceled int32
+		slowServer := newTestServer(t, slow(time.Second, &canceled))
+		fast := newTestServer(t, respond(http.StatusOK, `{"result":"fast"}`))
+
+		client := NewBalancedClient([]Endpoint{{URL: slowServer.URL}, {URL: fast.URL}}, &BalancedClientOpts{
+			Strategy:          StrategyFailover,
+			IdempotentMethods: []string{"getBlock"},
+			HedgeDelay:        20 * time.Millisecond,
+		})
+
+		start := time.Now()
+		res, err := client.Call(context.Background(), "getBlock", 1)
+		check.Nil(err)
+		check.Equal("fast", res.Result)
+		check.Less(time.Since(start), 500*time.Millisecond)
+		check.Equal(1, fast.requests())
+
+		// the slow request must be canceled after the fast one succeeded
+		check.Eventually(func() bool {
+			return atomic.LoadInt32(&canceled) == 1
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("fast calls should not be hedged", func(t *testing.T) {
+		s1 := newTestServer(t, respond(http.StatusOK, `{"result":1}`))
+		s2 := newTestServer(t, respond(http.StatusOK, `{"result":2}`))
+
+		client := NewBalancedClient([]Endpoint{{URL: s1.URL}, {URL: s2.URL}}, &BalancedClientOpts{
+			Strategy:          StrategyFailover,
+			IdempotentMethods: []string{"getBlock"},
+			HedgeDelay:        time.Second,
+		})
+
+		res, err := client.Call(context.Background(), "getBlock", 1)
+		check.Nil(err)
+		check.Equal(json.Number("1"), res.Result)
+		check.Equal(0, s2.requests())
+	})
+
+	t.Run("non idempotent calls should not be hedged", func(t *testing.T) {
+		var canceled int32
+		slowServer := newTestServer(t, slow(50*time.Millisecond, &canceled))
+		fast := newTestServer(t, respond(http.StatusOK, `{"result":"fast"}`))
+
+		client := NewBalancedClient([]Endpoint{{URL: slowServer.URL}, {URL: fast.URL}}, &BalancedClientOpts{
+			Strategy:          StrategyFailover,
+			IdempotentMethods: []string{"getBlock"},
+			HedgeDelay:        5 * time.Millisecond,
+		})
+
+		res, err := client.Call(context.Background(), "sendTransaction", 1)
+		check.Nil(err)
+		check.Equal("slow", res.Result)
+		check.Equal(0, fast.requests())
+	})
+
+	t.Run("hedged batch calls should return the first response", func(t *testing.T) {
+		var canceled int32
+		slowServer := newTestServer(t, slow(time.Second, &canceled))
+		fast := newTestServer(t, respond(http.StatusOK, `[{"result":"fast","id":0}]`))
+
+		client := NewBalancedClient([]Endpoint{{URL: slowServer.URL}, {URL: fast.URL}}, &BalancedClientOpts{
+			Strategy:          StrategyFailover,
+			IdempotentMethods: []string{"getBlock"},
+			HedgeDelay:        20 * time.Millisecond,
+		})
+
+		res, err := client.CallBatch(context.Background(), RPCRequests{NewRequest("getBlock", 1)})
+		check.Nil(err)
+		check.Equal("fast", res[0].Result)
+	})
+
+	t.Run("rpc errors should not win while other requests are pending", func(t *testing.T) {
+		failing := newTestServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+			time.Sleep(30 * time.Millisecond)
+			respond(http.StatusOK, `{"error":{"code":-32000,"message":"not synced"}}`)(w, r, body)
+		})
+		var canceled int32
+		slowServer := newTestServer(t, slow(100*time.Millisecond, &canceled))
+
+		client := NewBalancedClient([]Endpoint{{URL: failing.URL}, {URL: slowServer.URL}}, &BalancedClientOpts{
+			Strategy:          StrategyFailover,
+			IdempotentMethods: []string{"getBlock"},
+			HedgeDelay:        5 * time.Millisecond,
+		})
+
+		res, err := client.Call(context.Background(), "getBlock", 1)
+		check.Nil(err)
+		check.Nil(res.Error)
+		check.Equal("slow", res.Result)
+
+		// without another successful response the rpc error is returned
+		client = NewBalancedClient([]Endpoint{{URL: failing.URL}, {URL: failing.URL}}, &BalancedClientOpts{
+			Strategy:          StrategyFailover,
+			IdempotentMethods: []string{"getBlock"},
+			HedgeDelay:        5 * time.Millisecond,
+		})
+
+		res, err = client.Call(context.Background(), "getBlock", 1)
+		check.Nil(err)
+		check.Equal("not synced", res.Error.Message)
+	})
+
+	t.Run("percentile without delay should not hedge until enough latencies were observed", func(t *testing.T) {
+		s1 := newTestServer(t, respond(http.StatusOK, `{"result":1}`))
+		s2 := newTestServer(t, respond(http.StatusOK, `{"result":2}`))
+
+		client := NewBalancedClient([]Endpoint{{URL: s1.URL}, {URL: s2.URL}}, &BalancedClientOpts{
+			IdempotentMethods: []string{"getBlock"},
+			HedgePercentile:   0.9,
+		})
+
+		for i := 0; i < 5; i++ {
+			_, err := client.Call(context.Background(), "getBlock")
+			check.Nil(err)
+		}
+		check.Equal(5, s1.requests()+s2.requests())
+
+		// every completed request is observed, including failed ones
+		failing := newTestServer(t, respond(http.StatusBadRequest, `{}`))
+		client = NewBalancedClient([]Endpoint{{URL: failing.URL}}, &BalancedClientOpts{
+			IdempotentMethods: []string{"getBlock"},
+			HedgePercentile:   0.9,
+		})
+		for i := 0; i < 3; i++ {
+			client.Call(context.Background(), "getBlock")
+		}
+		check.Equal(3, client.hedge.latencies["getBlock"].count)
+	})
+}
+
+func TestLatencyWindow(t *testing.T) {
+	check := assert.New(t)
+
+	delayFor := func(h *hedging, method string) time.Duration {
+		delay, ok := h.delayFor(method)
+		check.True(ok)
+		return delay
+	}
+
+	h := newHedging(&BalancedClientOpts{HedgeDelay: time.Second, HedgePercentile: 0.9})
+	check.Equal(time.Second, delayFor(h, "getBlock"))
+
+	for i := 1; i <= 100; i++ {
+		h.observe("getBlock", time.Duration(i)*time.Millisecond)
+	}
+	check.Equal(90*time.Millisecond, delayFor(h, "getBlock"))
+	check.Equal(time.Second, delayFor(h, "other"))
+
+	// old samples are dropped once the window is full
+	for i := 0; i < latencyWindowSize; i++ {
+		h.observe("getBlock", time.Millisecond)
+	}
+	check.Equal(time.Millisecond, delayFor(h, "getBlock"))
+
+	// without HedgeDelay calls are not hedged until enough latencies were observed
+	h = newHedging(&BalancedClientOpts{HedgePercentile: 0.9})
+	_, ok := h.delayFor("getBlock")
+	check.False(ok)
+	for i := 0; i < minLatencySamples; i++ {
+		h.observe("getBlock", time.Millisecond)
+	}
+	check.Equal(time.Millisecond, delayFor(h, "getBlock"))
+}
@@ -0,0 +1,142 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Hooks are callbacks that are invoked at well defined points of every call (see RPCClientOpts.Hooks).
+// All of them are optional. They are called synchronously, so they should return quickly.
+//
+// OnRequest: called right before the http request is sent.
+//
+// OnResponse: called when a call finished without error (the responses may still hold RPCErrors).
+//
+// OnError: called when a call failed, including calls rejected before a request was sent.
+//
+// OnRetry: called before a call is sent again, e.g. by a BalancedClient failing over to another endpoint.
+//
+// Events and their fields must not be modified.
+type Hooks struct {
+	OnRequest  func(ctx context.Context, event *RequestEvent)
+	OnResponse func(ctx context.Context, event *CallEvent)
+	OnError    func(ctx context.Context, event *CallEvent)
+	OnRetry    func(ctx context.Context, event *RetryEvent)
+}
+
+// RequestEvent is passed to Hooks.OnRequest.
+//
+// Requests: the JSON-RPC requests of the call, a single one if Batch is false.
+//
+// Body: the encoded request body.
+//
+// HTTPRequest: the http request that is about to be sent.
+type RequestEvent struct {
+	Requests    []*RPCRequest
+	Batch       bool
+	Body        []byte
+	HTTPRequest *http.Request
+}
+
+// CallEvent is passed to Hooks.OnResponse and Hooks.OnError.
+//
+// Requests and Batch: see RequestEvent.
+//
+// RequestBody and HTTPRequest: the encoded request body and the http request, nil if the call failed before.
+//
+// HTTPResponse and ResponseBody: the http response and its body (already read), nil if no response was received.
+//
+// Responses: the decoded JSON-RPC responses, nil if the body could not be decoded.
+//
+// Duration: the duration of the whole call, including the time spent waiting for rate limits and queues.
+//
+// Err and ErrorClass: the error returned by the call and its classification (see ClassifyError()).
+type CallEvent struct {
+	Requests     []*RPCRequest
+	Batch        bool
+	RequestBody  []byte
+	HTTPRequest  *http.Request
+	HTTPResponse *http.Response
+	ResponseBody []byte
+	Responses    []*RPCResponse
+	Duration     time.Duration
+	Err          error
+	ErrorClass   ErrorClass
+}
+
+// RetryEvent is passed to Hooks.OnRetry.
+//
+// Method: the method of the call, empty for batch calls.
+//
+// Attempt: number of the retry, starting at 1.
+//
+// Endpoint: the endpoint the call is sent to next.
+//
+// Err and ErrorClass: the error of the previous attempt and its classification.
+type RetryEvent struct {
+	Method     string
+	Attempt    int
+	Endpoint   string
+	Err        error
+	ErrorClass ErrorClass
+}
+
+func (h *Hooks) request(ctx context.Context, requests []*RPCRequest, batch bool, ex *exchange) {
+	if h == nil || h.OnRequest == nil {
+		return
+	}
+
+	h.OnRequest(ctx, &RequestEvent{
+		Requests:    requests,
+		Batch:       batch,
+		Body:        ex.requestBody,
+		HTTPRequest: ex.request,
+	})
+}
+
+// usesBodies reports whether a hook receives the response body.
+func (h *Hooks) usesBodies() bool {
+	return h != nil && (h.OnResponse != nil || h.OnError != nil)
+}
+
+func (h *Hooks) finish(ctx context.Context, requests []*RPCRequest, batch bool, responses []*RPCResponse, ex *exchange, duration time.Duration, err error) {
+	if h == nil {
+		return
+	}
+
+	hook := h.OnResponse
+	if err != nil {
+		hook = h.OnError
+	}
+	if hook == nil {
+		return
+	}
+
+	hook(ctx, &CallEvent{
+		Requests:     requests,
+		Batch:        batch,
+		RequestBody:  ex.requestBody,
+		HTTPRequest:  ex.request,
+		HTTPResponse: ex.response,
+		ResponseBody: ex.responseBody,
+		Responses:    responses,
+		Duration:     duration,
+		Err:          err,
+		ErrorClass:   ClassifyError(err),
+	})
+}
+
+func (h *Hooks) retry(ctx context.Context, method string, attempt int, endpoint string, err error) {
+	if h == nil || h.OnRetry == nil {
+		return
+	}
+
+	h.OnRetry(ctx, &RetryEvent{
+		Method:     method,
+		Attempt:    attempt,
+		Endpoint:   endpoint,
+		Err:        err,
+		ErrorClass: ClassifyError(err),
+	})
+}
@@ -0,0 +1,153 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// hookRecorder records the events of all hooks
+type hookRecorder struct {
+	mu        sync.Mutex
+	requests  []*RequestEvent
+	responses []*CallEvent
+	errors    []*CallEvent
+	retries   []*RetryEvent
+}
+
+func (r *hookRecorder) hooks() *Hooks {
+	return &Hooks{
+		OnRequest: func(ctx context.Context, event *RequestEvent) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.requests = append(r.requests, event)
+		},
+		OnResponse: func(ctx context.Context, event *CallEvent) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.responses = append(r.responses, event)
+		},
+		OnError: func(ctx context.Context, event *CallEvent) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.errors = append(r.errors, event)
+		},
+		OnRetry: func(ctx context.Context, event *RetryEvent) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.retries = append(r.retries, event)
+		},
+	}
+}
+
+func TestHooks(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("successful calls should fire OnRequest and OnResponse", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":"ok","id":0}`))
+		recorder := &hookRecorder{}
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{
+			Hooks:         recorder.hooks(),
+			CustomHeaders: map[string]string{"X-Tenant": "tenant"},
+		})
+
+		_, err := rpcClient.Call(context.Background(), "getBlock", 1)
+		check.Nil(err)
+
+		check.Len(recorder.requests, 1)
+		check.False(recorder.requests[0].Batch)
+		check.Equal("getBlock", recorder.requests[0].Requests[0].Method)
+		check.Equal(`{"method":"getBlock","params":[1],"id":0,"jsonrpc":"2.0"}`, string(recorder.requests[0].Body))
+		check.Equal("tenant", recorder.requests[0].HTTPRequest.Header.Get("X-Tenant"))
+
+		check.Len(recorder.responses, 1)
+		event := recorder.responses[0]
+		check.Equal(recorder.requests[0].Body, event.RequestBody)
+		check.Equal(http.StatusOK, event.HTTPResponse.StatusCode)
+		check.Equal(`{"result":"ok","id":0}`, string(event.ResponseBody))
+		check.Equal("ok", event.Responses[0].Result)
+		check.Greater(event.Duration.Nanoseconds(), int64(0))
+		check.Nil(event.Err)
+		check.Equal(ErrorClassNone, event.ErrorClass)
+		check.Empty(recorder.errors)
+	})
+
+	t.Run("failed calls should fire OnError", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusInternalServerError, `[{"error":{"code":-32000,"message":"failed"},"id":0}]`))
+		recorder := &hookRecorder{}
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{
+			Hooks:     recorder.hooks(),
+			RateLimit: &RateLimitOpts{RateLimit: RateLimit{Rate: 0.001, Burst: 2}, FailFast: true},
+		})
+
+		_, err := rpcClient.CallBatch(context.Background(), RPCRequests{NewRequest("a"), NewRequest("b")})
+		check.NotNil(err)
+		_, err = rpcClient.Call(context.Background(), "c")
+		check.NotNil(err)
+
+		check.Len(recorder.requests, 1)
+		check.True(recorder.requests[0].Batch)
+		check.Empty(recorder.responses)
+		check.Len(recorder.errors, 2)
+
+		event := recorder.errors[0]
+		check.True(event.Batch)
+		check.Len(event.Requests, 2)
+		check.Equal(ErrorClassHTTP, event.ErrorClass)
+		check.Equal(http.StatusInternalServerError, event.HTTPResponse.StatusCode)
+		check.Equal(-32000, event.Responses[0].Error.Code)
+
+		// rejected by the rate limiter before a request was sent
+		event = recorder.errors[1]
+		check.Equal(ErrorClassRejected, event.ErrorClass)
+		check.Nil(event.HTTPRequest)
+		check.Nil(event.RequestBody)
+		check.Nil(event.HTTPResponse)
+	})
+
+	t.Run("failover should fire OnRetry", func(t *testing.T) {
+		failing := newTestServer(t, respond(http.StatusBadGateway, ""))
+		ok := newTestServer(t, respond(http.StatusOK, `{"result":"ok"}`))
+		recorder := &hookRecorder{}
+		client := NewBalancedClient([]Endpoint{{URL: failing.URL}, {URL: ok.URL}}, &BalancedClientOpts{
+			Strategy:          StrategyFailover,
+			IdempotentMethods: []string{"getBlock"},
+			ClientOpts:        &RPCClientOpts{Hooks: recorder.hooks()},
+		})
+		defer client.Close()
+
+		_, err := client.Call(context.Background(), "getBlock")
+		check.Nil(err)
+
+		check.Len(recorder.retries, 1)
+		check.Equal("getBlock", recorder.retries[0].Method)
+		check.Equal(1, recorder.retries[0].Attempt)
+		check.Equal(ok.URL, recorder.retries[0].Endpoint)
+		check.Equal(ErrorClassHTTP, recorder.retries[0].ErrorClass)
+		check.Len(recorder.errors, 1)
+		check.Len(recorder.responses, 1)
+	})
+
+	t.Run("hedged failover should fire OnRetry", func(t *testing.T) {
+		failing := newTestServer(t, respond(http.StatusBadGateway, ""))
+		ok := newTestServer(t, respond(http.StatusOK, `{"result":"ok"}`))
+		recorder := &hookRecorder{}
+		client := NewBalancedClient([]Endpoint{{URL: failing.URL}, {URL: ok.URL}}, &BalancedClientOpts{
+			Strategy:          StrategyFailover,
+			IdempotentMethods: []string{"getBlock"},
+			HedgeDelay:        time.Hour,
+			ClientOpts:        &RPCClientOpts{Hooks: recorder.hooks()},
+		})
+		defer client.Close()
+
+		_, err := client.Call(context.Background(), "getBlock")
+		check.Nil(err)
+
+		check.Len(recorder.retries, 1)
+		check.Equal(ok.URL, recorder.retries[0].Endpoint)
+	})
+}
@@ -2,11 +2,15 @@ package jsonrpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -1012,6 +1016,50 @@ type Drink struct {
 	Ingredients []string `json:"ingredients"`
 }
 
+func TestReadResponse(t *testing.T) {
+	check := assert.New(t)
+
+	result := strings.Repeat("x", 10000)
+	body := `{"jsonrpc":"2.0","id":0,"result":"` + result + `"}`
+	s := newTestServer(t, respond(http.StatusOK, body))
+
+	t.Run("body should only be kept if it is used", func(t *testing.T) {
+		ctx, meta := WithMetaCollector(context.Background())
+		ex := &exchange{}
+		res, err := NewClient(s.URL).(*rpcClient).send(ctx, NewRequest("getBlock"), ex)
+		check.Nil(err)
+		check.Equal(result, res.Result)
+		check.Nil(ex.responseBody)
+		check.Equal(len(body), ex.responseSize)
+
+		collectMeta(ctx, ex)
+		check.Equal(len(body), meta.All()[0].ResponseBytes)
+	})
+
+	t.Run("hooks should get the whole body", func(t *testing.T) {
+		var responseBody []byte
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{
+			Hooks: &Hooks{OnResponse: func(ctx context.Context, event *CallEvent) {
+				responseBody = event.ResponseBody
+			}},
+		})
+
+		_, err := rpcClient.Call(context.Background(), "getBlock")
+		check.Nil(err)
+		check.Equal(body, string(responseBody))
+	})
+
+	t.Run("snippet should be the beginning of the body", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusInternalServerError, "{"+strings.Repeat("x", 10000)))
+
+		_, err := NewClient(s.URL).Call(context.Background(), "getBlock")
+
+		var httpErr *HTTPError
+		check.True(errors.As(err, &httpErr))
+		check.Equal("{"+strings.Repeat("x", 4095), string(httpErr.Body))
+	})
+}
+
 type Planet struct {
 	Name       string     `json:"name"`
 	Properties Properties `json:"properties"`
@@ -1021,3 +1069,51 @@ type Properties struct {
 	Distance int    `json:"distance"`
 	Color    string `json:"color"`
 }
+
+// testServer is a test server that counts the requests it received and records their headers and bodies
+type testServer struct {
+	*httptest.Server
+	count   int64
+	headers chan http.Header
+	bodies  chan string
+}
+
+// testHandler answers a request of a testServer, body is the already consumed request body
+type testHandler func(w http.ResponseWriter, r *http.Request, body []byte)
+
+// newTestServer returns a testServer that answers every request with handle.
+// Headers and bodies of the first 100 requests are recorded.
+func newTestServer(t *testing.T, handle testHandler) *testServer {
+	s := &testServer{
+		headers: make(chan http.Header, 100),
+		bodies:  make(chan string, 100),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the body must be consumed so that the server notices when the client goes away
+		body, _ := io.ReadAll(r.Body)
+		atomic.AddInt64(&s.count, 1)
+		select {
+		case s.headers <- r.Header:
+		default:
+		}
+		select {
+		case s.bodies <- string(body):
+		default:
+		}
+		handle(w, r, body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testServer) requests() int {
+	return int(atomic.LoadInt64(&s.count))
+}
+
+// respond answers every request with status and body
+func respond(status int, body string) testHandler {
+	return func(w http.ResponseWriter, r *http.Request, _ []byte) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
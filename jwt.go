@@ -0,0 +1,277 @@
+package jsonrpc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// JWTSignerOpts can be provided to NewJWTSigner() to change the configuration of the signer.
+//
+// KeyID: sent as "kid" in the token header.
+//
+// Issuer, Subject, Audience: the "iss", "sub" and "aud" claims, omitted if empty.
+//
+// TTL: lifetime of a token, used for the "exp" claim, defaults to 1 minute.
+//
+// Claims: additional claims of every token, they overwrite the standard claims.
+//
+// ClaimsFunc: returns additional claims for a request, they overwrite all other claims.
+// If an error is returned, the call fails without sending a request.
+//
+// RequestHash: if true, every token contains the claim "req_hash", the base64url encoded SHA-256 of
+// the http method, request path and body, separated by newlines. This binds a token to a single request.
+//
+// CacheInterval: if greater than 0, a token is reused for this interval instead of minting one per request.
+// It must be shorter than TTL. Ignored if RequestHash or ClaimsFunc is set, since their claims differ per request.
+type JWTSignerOpts struct {
+	KeyID         string
+	Issuer        string
+	Subject       string
+	Audience      []string
+	TTL           time.Duration
+	Claims        map[string]interface{}
+	ClaimsFunc    func(ctx context.Context, request *http.Request, body []byte) (map[string]interface{}, error)
+	RequestHash   bool
+	CacheInterval time.Duration
+}
+
+// JWTSigner is an AuthProvider that mints a JWT for every request and sends it as bearer token.
+//
+// The algorithm is chosen by the type of the key: HS256 for []byte, RS256 for *rsa.PrivateKey,
+// ES256 for *ecdsa.PrivateKey (P-256) and EdDSA for ed25519.PrivateKey.
+//
+// Every token has the claims "iat", "nbf", "exp" and a random "jti".
+//
+// e.g.
+//
+//	signer, err := jsonrpc.NewJWTSigner(privateKey, &jsonrpc.JWTSignerOpts{Issuer: "my-service", Audience: []string{"rpc"}})
+//	rpcClient := jsonrpc.NewClientWithOpts(endpoint, &jsonrpc.RPCClientOpts{Auth: signer})
+//
+// JWTSigner is created using the factory function NewJWTSigner().
+type JWTSigner struct {
+	key  interface{}
+	alg  string
+	opts JWTSignerOpts
+	now  func() time.Time
+
+	mu       sync.Mutex
+	cached   string
+	cachedAt time.Time
+}
+
+// NewJWTSigner returns a new JWTSigner, or an error if the key is not supported.
+//
+// key: []byte, *rsa.PrivateKey, *ecdsa.PrivateKey or ed25519.PrivateKey.
+//
+// opts: JWTSignerOpts is used to provide custom configuration, can be nil.
+func NewJWTSigner(key interface{}, opts *JWTSignerOpts) (*JWTSigner, error) {
+	s := &JWTSigner{
+		key: key,
+		now: time.Now,
+	}
+
+	switch k := key.(type) {
+	case []byte:
+		if len(k) == 0 {
+			return nil, errors.New("jwt: empty hmac key")
+		}
+		s.alg = "HS256"
+	case *rsa.PrivateKey:
+		s.alg = "RS256"
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.New("jwt: ecdsa key must use curve P-256")
+		}
+		s.alg = "ES256"
+	case ed25519.PrivateKey:
+		if len(k) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("jwt: ed25519 key must have %v bytes, got %v", ed25519.PrivateKeySize, len(k))
+		}
+		s.alg = "EdDSA"
+	default:
+		return nil, fmt.Errorf("jwt: unsupported key type %T", key)
+	}
+
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.TTL <= 0 {
+		s.opts.TTL = time.Minute
+	}
+	if s.opts.CacheInterval >= s.opts.TTL {
+		return nil, fmt.Errorf("jwt: cache interval %v must be shorter than ttl %v", s.opts.CacheInterval, s.opts.TTL)
+	}
+
+	return s, nil
+}
+
+// Authenticate sets the Authorization header of the request to a bearer token.
+func (s *JWTSigner) Authenticate(ctx context.Context, request *http.Request, body []byte) error {
+	token, err := s.token(ctx, request, body)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Refresh discards a cached token, so that the next request gets a new one.
+func (s *JWTSigner) Refresh(ctx context.Context, response *http.Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cached = ""
+
+	return nil
+}
+
+// token returns the cached token or mints a new one.
+func (s *JWTSigner) token(ctx context.Context, request *http.Request, body []byte) (string, error) {
+	if s.opts.CacheInterval <= 0 || s.opts.RequestHash || s.opts.ClaimsFunc != nil {
+		return s.mint(ctx, request, body)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.cached != "" && now.Sub(s.cachedAt) < s.opts.CacheInterval {
+		return s.cached, nil
+	}
+
+	token, err := s.mint(ctx, request, body)
+	if err != nil {
+		return "", err
+	}
+
+	s.cached, s.cachedAt = token, now
+	return token, nil
+}
+
+// mint creates and signs a new token.
+func (s *JWTSigner) mint(ctx context.Context, request *http.Request, body []byte) (string, error) {
+	header := map[string]string{"alg": s.alg, "typ": "JWT"}
+	if s.opts.KeyID != "" {
+		header["kid"] = s.opts.KeyID
+	}
+
+	claims, err := s.claims(ctx, request, body)
+	if err != nil {
+		return "", err
+	}
+
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("jwt: encode header: %w", err)
+	}
+	encodedClaims, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("jwt: encode claims: %w", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(encodedHeader) + "." + base64.RawURLEncoding.EncodeToString(encodedClaims)
+	signature, err := s.sign([]byte(signingInput))
+	if err != nil {
+		return "", fmt.Errorf("jwt: sign token: %w", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// claims returns the claims of a new token.
+func (s *JWTSigner) claims(ctx context.Context, request *http.Request, body []byte) (map[string]interface{}, error) {
+	jti, err := RandomNonce()
+	if err != nil {
+		return nil, fmt.Errorf("jwt: create jti: %w", err)
+	}
+
+	now := s.now()
+	claims := map[string]interface{}{
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(s.opts.TTL).Unix(),
+		"jti": jti,
+	}
+	if s.opts.Issuer != "" {
+		claims["iss"] = s.opts.Issuer
+	}
+	if s.opts.Subject != "" {
+		claims["sub"] = s.opts.Subject
+	}
+	switch len(s.opts.Audience) {
+	case 0:
+	case 1:
+		claims["aud"] = s.opts.Audience[0]
+	default:
+		claims["aud"] = s.opts.Audience
+	}
+	if s.opts.RequestHash {
+		claims["req_hash"] = requestHash(request, body)
+	}
+
+	for k, v := range s.opts.Claims {
+		claims[k] = v
+	}
+
+	if s.opts.ClaimsFunc != nil {
+		extra, err := s.opts.ClaimsFunc(ctx, request, body)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range extra {
+			claims[k] = v
+		}
+	}
+
+	return claims, nil
+}
+
+// requestHash returns the base64url encoded SHA-256 of http method, path and body.
+func requestHash(request *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(request.Method + "\n" + request.URL.EscapedPath() + "\n"))
+	h.Write(body)
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// sign signs the signing input with the algorithm of the key.
+func (s *JWTSigner) sign(input []byte) ([]byte, error) {
+	digest := sha256.Sum256(input)
+
+	switch key := s.key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case *rsa.PrivateKey:
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, sig, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// JWS uses the fixed size concatenation of r and s instead of ASN.1
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		sig.FillBytes(signature[32:])
+		return signature, nil
+	case ed25519.PrivateKey:
+		return ed25519.Sign(key, input), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", s.key)
+	}
+}
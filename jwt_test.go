@@ -0,0 +1,169 @@
+package jsonrpc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// parseJWT splits a bearer token and decodes its header and claims.
+func parseJWT(t *testing.T, authorization string) (header map[string]interface{}, claims map[string]interface{}, signingInput string, signature []byte) {
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	parts := strings.Split(token, ".")
+	if !assert.Len(t, parts, 3) {
+		t.FailNow()
+	}
+
+	decode := func(part string, v interface{}) {
+		b, err := base64.RawURLEncoding.DecodeString(part)
+		assert.Nil(t, err)
+		assert.Nil(t, json.Unmarshal(b, v))
+	}
+	decode(parts[0], &header)
+	decode(parts[1], &claims)
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.Nil(t, err)
+	return header, claims, parts[0] + "." + parts[1], signature
+}
+
+func TestJWTSigner(t *testing.T) {
+	check := assert.New(t)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPublic, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	verifiers := map[string]struct {
+		key    interface{}
+		verify func(input string, signature []byte) bool
+	}{
+		"HS256": {[]byte("secret"), func(input string, signature []byte) bool {
+			mac := hmac.New(sha256.New, []byte("secret"))
+			mac.Write([]byte(input))
+			return hmac.Equal(mac.Sum(nil), signature)
+		}},
+		"RS256": {rsaKey, func(input string, signature []byte) bool {
+			digest := sha256.Sum256([]byte(input))
+			return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], signature) == nil
+		}},
+		"ES256": {ecKey, func(input string, signature []byte) bool {
+			digest := sha256.Sum256([]byte(input))
+			r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+			return len(signature) == 64 && ecdsa.Verify(&ecKey.PublicKey, digest[:], r, s)
+		}},
+		"EdDSA": {edKey, func(input string, signature []byte) bool {
+			return ed25519.Verify(edPublic, []byte(input), signature)
+		}},
+	}
+
+	for alg, v := range verifiers {
+		t.Run(alg+" tokens should be verifiable", func(t *testing.T) {
+			s := newTestServer(t, respond(http.StatusOK, `{"result":"ok"}`))
+			signer, err := NewJWTSigner(v.key, &JWTSignerOpts{
+				KeyID:    "key-1",
+				Issuer:   "client",
+				Subject:  "service",
+				Audience: []string{"rpc"},
+				TTL:      time.Minute,
+				Claims:   map[string]interface{}{"scope": "read"},
+			})
+			check.Nil(err)
+			signer.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+			rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Auth: signer})
+			_, err = rpcClient.Call(context.Background(), "getBlock")
+			check.Nil(err)
+
+			header, claims, input, signature := parseJWT(t, (<-s.headers).Get("Authorization"))
+			check.Equal(map[string]interface{}{"alg": alg, "typ": "JWT", "kid": "key-1"}, header)
+			check.Equal("client", claims["iss"])
+			check.Equal("service", claims["sub"])
+			check.Equal("rpc", claims["aud"])
+			check.Equal("read", claims["scope"])
+			check.EqualValues(1700000000, claims["iat"])
+			check.EqualValues(1700000060, claims["exp"])
+			check.NotEmpty(claims["jti"])
+			check.True(v.verify(input, signature))
+		})
+	}
+
+	t.Run("a token should be minted per request", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":"ok"}`))
+		signer, _ := NewJWTSigner([]byte("secret"), nil)
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Auth: signer})
+
+		rpcClient.Call(context.Background(), "getBlock")
+		rpcClient.Call(context.Background(), "getBlock")
+		check.NotEqual((<-s.headers).Get("Authorization"), (<-s.headers).Get("Authorization"))
+	})
+
+	t.Run("a token should be cached per interval", func(t *testing.T) {
+		signer, err := NewJWTSigner([]byte("secret"), &JWTSignerOpts{TTL: time.Minute, CacheInterval: 30 * time.Second})
+		check.Nil(err)
+		now := time.Now()
+		signer.now = func() time.Time { return now }
+
+		request, _ := http.NewRequest("POST", "http://localhost/rpc", nil)
+		token := func() string {
+			check.Nil(signer.Authenticate(context.Background(), request, nil))
+			return request.Header.Get("Authorization")
+		}
+
+		first := token()
+		now = now.Add(20 * time.Second)
+		check.Equal(first, token())
+		now = now.Add(20 * time.Second)
+		second := token()
+		check.NotEqual(first, second)
+
+		signer.Refresh(context.Background(), nil)
+		check.NotEqual(second, token())
+	})
+
+	t.Run("request hash should bind the token to the body", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":"ok"}`))
+		signer, _ := NewJWTSigner([]byte("secret"), &JWTSignerOpts{
+			RequestHash: true,
+			ClaimsFunc: func(ctx context.Context, request *http.Request, body []byte) (map[string]interface{}, error) {
+				return map[string]interface{}{"body_size": len(body)}, nil
+			},
+		})
+		rpcClient := NewClientWithOpts(s.URL+"/rpc", &RPCClientOpts{Auth: signer})
+
+		_, err := rpcClient.Call(context.Background(), "getBlock", 1)
+		check.Nil(err)
+
+		_, claims, _, _ := parseJWT(t, (<-s.headers).Get("Authorization"))
+		body := <-s.bodies
+		digest := sha256.Sum256([]byte("POST\n/rpc\n" + body))
+		check.Equal(base64.RawURLEncoding.EncodeToString(digest[:]), claims["req_hash"])
+		check.EqualValues(len(body), claims["body_size"])
+	})
+
+	t.Run("unsupported keys should be rejected", func(t *testing.T) {
+		p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		for _, key := range []interface{}{"secret", []byte{}, p384, &rsaKey.PublicKey, ed25519.PrivateKey("short")} {
+			_, err := NewJWTSigner(key, nil)
+			check.Error(err)
+		}
+
+		_, err := NewJWTSigner([]byte("secret"), &JWTSignerOpts{TTL: time.Minute, CacheInterval: time.Minute})
+		check.Error(err)
+	})
+}
@@ -0,0 +1,204 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+// alwaysRedactedHeaders are never logged in clear text.
+var alwaysRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// callLogger logs calls and their payloads to a slog.Logger.
+type callLogger struct {
+	logger   *slog.Logger
+	endpoint string
+	fields   map[string]bool
+	headers  map[string]bool
+}
+
+func newCallLogger(logger *slog.Logger, endpoint string, fields []string, headers []string) *callLogger {
+	if logger == nil {
+		return nil
+	}
+
+	l := &callLogger{
+		logger:   logger,
+		endpoint: redactURL(endpoint),
+		fields:   make(map[string]bool),
+		headers:  make(map[string]bool),
+	}
+
+	for _, field := range fields {
+		l.fields[strings.ToLower(field)] = true
+	}
+	for _, header := range append(alwaysRedactedHeaders, headers...) {
+		l.headers[http.CanonicalHeaderKey(header)] = true
+	}
+
+	return l
+}
+
+// logCall logs a finished single call.
+func (l *callLogger) logCall(ctx context.Context, request *RPCRequest, response *RPCResponse, ex *exchange, latency time.Duration, err error) {
+	if l == nil {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", request.Method),
+		slog.Int("id", request.ID),
+	}
+
+	var rpcErr *RPCError
+	if response != nil {
+		rpcErr = response.Error
+	}
+
+	l.log(ctx, "rpc call", attrs, rpcErr, ex, latency, err)
+}
+
+// logsBodies reports whether the bodies of requests made with ctx are logged.
+func (l *callLogger) logsBodies(ctx context.Context) bool {
+	return l != nil && l.logger.Enabled(ctx, slog.LevelDebug)
+}
+
+// logBatch logs a finished batch call.
+func (l *callLogger) logBatch(ctx context.Context, requests []*RPCRequest, responses []*RPCResponse, ex *exchange, latency time.Duration, err error) {
+	if l == nil {
+		return
+	}
+
+	methods := make([]string, len(requests))
+	for i, request := range requests {
+		methods[i] = request.Method
+	}
+	attrs := []slog.Attr{
+		slog.Any("methods", methods),
+		slog.Int("batch_size", len(requests)),
+	}
+
+	var rpcErr *RPCError
+	for _, response := range responses {
+		if response != nil && response.Error != nil {
+			rpcErr = response.Error
+			break
+		}
+	}
+
+	l.log(ctx, "rpc batch call", attrs, rpcErr, ex, latency, err)
+}
+
+// log writes the summary record of a call and, at debug level, the record with its payload.
+//
+// rpcErr is the (first) RPCError of the response, nil if there was none.
+func (l *callLogger) log(ctx context.Context, msg string, attrs []slog.Attr, rpcErr *RPCError, ex *exchange, latency time.Duration, err error) {
+	endpoint := l.endpoint
+	if ex.request != nil {
+		endpoint = ex.request.URL.Redacted()
+	}
+	attrs = append(attrs, slog.String("endpoint", endpoint), slog.Duration("latency", latency))
+	if status := ex.statusCode(); status != 0 {
+		attrs = append(attrs, slog.Int("status", status))
+	}
+
+	level := slog.LevelInfo
+	switch {
+	case err != nil:
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error_class", ClassifyError(err).String()), slog.String("error", err.Error()))
+	case rpcErr != nil:
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String("error_class", "rpc"), slog.Int("rpc_error_code", rpcErr.Code))
+	}
+
+	l.logger.LogAttrs(ctx, level, msg, attrs...)
+
+	if ex.request == nil || !l.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	payload := append(attrs[:len(attrs):len(attrs)],
+		slog.Any("request_headers", l.redactHeaders(ex.request.Header)),
+		slog.String("request_body", l.redactBody(ex.requestBody)),
+	)
+	if ex.response != nil {
+		payload = append(payload,
+			slog.Any("response_headers", l.redactHeaders(ex.response.Header)),
+			slog.String("response_body", l.redactBody(ex.responseBody)),
+		)
+	}
+
+	l.logger.LogAttrs(ctx, slog.LevelDebug, msg+" payload", payload...)
+}
+
+// redactHeaders returns the headers with the values of sensitive headers replaced.
+func (l *callLogger) redactHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for k, v := range header {
+		if l.headers[http.CanonicalHeaderKey(k)] {
+			headers[k] = redacted
+		} else {
+			headers[k] = strings.Join(v, ", ")
+		}
+	}
+
+	return headers
+}
+
+// redactBody returns the JSON body with the values of sensitive fields replaced.
+// Bodies that are no valid JSON are returned unchanged.
+func (l *callLogger) redactBody(body []byte) string {
+	if len(l.fields) == 0 {
+		return string(body)
+	}
+
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return string(body)
+	}
+
+	redactedBody, err := json.Marshal(l.redactValue(v))
+	if err != nil {
+		return string(body)
+	}
+
+	return string(redactedBody)
+}
+
+func (l *callLogger) redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, value := range v {
+			if l.fields[strings.ToLower(k)] {
+				v[k] = redacted
+			} else {
+				v[k] = l.redactValue(value)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = l.redactValue(value)
+		}
+	}
+
+	return v
+}
+
+// redactURL returns the url with its password redacted, see url.URL.Redacted().
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
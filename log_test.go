@@ -0,0 +1,116 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// logRecords decodes the records written by a slog.JSONHandler
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLogger(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("calls should be logged with redacted payload", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":{"token":"secret-result","name":"Alex"},"id":0}`))
+		buf := &bytes.Buffer{}
+		rpcClient := NewClientWithOpts(strings.Replace(s.URL, "http://", "http://user:pass@", 1), &RPCClientOpts{
+			Logger:           slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+			CustomHeaders:    map[string]string{"Authorization": "Bearer secret-token", "X-Api-Key": "secret-key", "X-Tenant": "tenant"},
+			LogRedactFields:  []string{"Password", "token"},
+			LogRedactHeaders: []string{"x-api-key"},
+		})
+
+		_, err := rpcClient.Call(context.Background(), "login", &struct {
+			User     string `json:"user"`
+			Password string `json:"password"`
+		}{"alex", "secret-password"})
+		check.Nil(err)
+
+		check.NotContains(buf.String(), "secret")
+		check.NotContains(buf.String(), "pass@")
+
+		records := logRecords(t, buf)
+		check.Len(records, 2)
+
+		check.Equal("INFO", records[0]["level"])
+		check.Equal("rpc call", records[0]["msg"])
+		check.Equal("login", records[0]["method"])
+		check.Equal(float64(0), records[0]["id"])
+		check.Equal(float64(200), records[0]["status"])
+		check.Contains(records[0]["endpoint"], "user:xxxxx@")
+		check.NotNil(records[0]["latency"])
+		check.Nil(records[0]["request_body"])
+
+		check.Equal("DEBUG", records[1]["level"])
+		check.Equal(`{"id":0,"jsonrpc":"2.0","method":"login","params":{"password":"[REDACTED]","user":"alex"}}`, records[1]["request_body"])
+		check.Equal(`{"id":0,"result":{"name":"Alex","token":"[REDACTED]"}}`, records[1]["response_body"])
+		headers := records[1]["request_headers"].(map[string]interface{})
+		check.Equal("[REDACTED]", headers["Authorization"])
+		check.Equal("[REDACTED]", headers["X-Api-Key"])
+		check.Equal("tenant", headers["X-Tenant"])
+	})
+
+	t.Run("bodies should not be logged above debug level", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":"ok","id":0}`))
+		buf := &bytes.Buffer{}
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Logger: slog.New(slog.NewJSONHandler(buf, nil))})
+
+		rpcClient.Call(context.Background(), "getBlock", 1)
+		records := logRecords(t, buf)
+		check.Len(records, 1)
+		check.Equal("getBlock", records[0]["method"])
+	})
+
+	t.Run("errors should be logged with their class", func(t *testing.T) {
+		rpcErrServer := newTestServer(t, respond(http.StatusOK, `{"error":{"code":-32601,"message":"method not found"},"id":0}`))
+		httpErrServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer httpErrServer.Close()
+
+		buf := &bytes.Buffer{}
+		logger := slog.New(slog.NewJSONHandler(buf, nil))
+
+		NewClientWithOpts(rpcErrServer.URL, &RPCClientOpts{Logger: logger}).Call(context.Background(), "unknown")
+		NewClientWithOpts(httpErrServer.URL, &RPCClientOpts{Logger: logger}).Call(context.Background(), "getBlock")
+		NewClientWithOpts(httpErrServer.URL, &RPCClientOpts{
+			Logger:    logger,
+			RateLimit: &RateLimitOpts{RateLimit: RateLimit{Rate: 0.001, Burst: 1}, FailFast: true},
+		}).CallBatch(context.Background(), RPCRequests{NewRequest("a"), NewRequest("b")})
+
+		records := logRecords(t, buf)
+		check.Len(records, 3)
+
+		check.Equal("WARN", records[0]["level"])
+		check.Equal("rpc", records[0]["error_class"])
+		check.Equal(float64(-32601), records[0]["rpc_error_code"])
+
+		check.Equal("ERROR", records[1]["level"])
+		check.Equal("http", records[1]["error_class"])
+		check.Equal(float64(502), records[1]["status"])
+
+		check.Equal("rpc batch call", records[2]["msg"])
+		check.Equal("rejected", records[2]["error_class"])
+		check.Equal([]interface{}{"a", "b"}, records[2]["methods"])
+		check.Equal(float64(2), records[2]["batch_size"])
+	})
+}
@@ -0,0 +1,208 @@
+package jsonrpc
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// ResponseMeta holds the metadata of the http request of a call.
+//
+// Endpoint: the endpoint the request was sent to, with a password of the url redacted.
+//
+// StatusCode and Header: status code and headers of the http response.
+//
+// RequestBytes and ResponseBytes: size of the request and response bodies.
+//
+// Timing: timing breakdown of the request.
+type ResponseMeta struct {
+	Endpoint      string
+	StatusCode    int
+	Header        http.Header
+	RequestBytes  int
+	ResponseBytes int
+	Timing        Timing
+}
+
+// Timing is the timing breakdown of an http request, recorded with net/http/httptrace.
+//
+// DNS, Connect and TLSHandshake: duration of these phases, 0 if they were skipped, e.g. because a connection was reused.
+//
+// TimeToFirstByte: time from sending the request until the first byte of the response was received.
+//
+// Total: time from sending the request until the response body was read.
+//
+// ReusedConnection: true if an idle connection was reused.
+type Timing struct {
+	DNS              time.Duration
+	Connect          time.Duration
+	TLSHandshake     time.Duration
+	TimeToFirstByte  time.Duration
+	Total            time.Duration
+	ReusedConnection bool
+}
+
+// MetaCollector collects the ResponseMeta of calls made with a context returned by WithMetaCollector().
+type MetaCollector struct {
+	mu    sync.Mutex
+	metas []ResponseMeta
+}
+
+type metaCollectorKey struct{}
+
+// WithMetaCollector returns a copy of ctx with a new MetaCollector attached.
+//
+// Every http request that is sent with the returned context is recorded in the collector, e.g.
+//
+//	ctx, meta := jsonrpc.WithMetaCollector(ctx)
+//	res, err := rpcClient.Call(ctx, "getBlock", 123)
+//	if last, ok := meta.Last(); ok {
+//		serverVersion := last.Header.Get("X-Server-Version")
+//	}
+//
+// Calls served by a ResponseCache are not recorded. Calls with a MetaCollector are never deduplicated (see DeduplicateMethods).
+func WithMetaCollector(ctx context.Context) (context.Context, *MetaCollector) {
+	collector := &MetaCollector{}
+	return context.WithValue(ctx, metaCollectorKey{}, collector), collector
+}
+
+func metaCollectorFrom(ctx context.Context) *MetaCollector {
+	collector, _ := ctx.Value(metaCollectorKey{}).(*MetaCollector)
+	return collector
+}
+
+// Last returns the metadata of the last finished request, false if no request was recorded.
+func (c *MetaCollector) Last() (ResponseMeta, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.metas) == 0 {
+		return ResponseMeta{}, false
+	}
+	return c.metas[len(c.metas)-1], true
+}
+
+// All returns the metadata of all recorded requests in the order they finished,
+// e.g. all attempts of a BalancedClient call.
+func (c *MetaCollector) All() []ResponseMeta {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]ResponseMeta(nil), c.metas...)
+}
+
+// collectMeta records the exchange in the MetaCollector of ctx, if there is one and a request was sent.
+func collectMeta(ctx context.Context, ex *exchange) {
+	collector := metaCollectorFrom(ctx)
+	if collector == nil || ex.request == nil {
+		return
+	}
+
+	meta := ResponseMeta{
+		Endpoint:      ex.request.URL.Redacted(),
+		RequestBytes:  len(ex.requestBody),
+		ResponseBytes: ex.responseSize,
+	}
+	if ex.response != nil {
+		meta.StatusCode = ex.response.StatusCode
+		meta.Header = ex.response.Header
+	}
+	if ex.timing != nil {
+		meta.Timing = ex.timing.get()
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.metas = append(collector.metas, meta)
+}
+
+// timingTrace records the Timing of a request.
+type timingTrace struct {
+	mu       sync.Mutex
+	start    time.Time
+	dnsStart time.Time
+	dialAt   time.Time
+	tlsStart time.Time
+	timing   Timing
+}
+
+// traceTiming returns the request with an httptrace.ClientTrace that records its timing in ex, if a MetaCollector
+// is attached to its context. It must be called right before the request is sent, so that the timing does not
+// include the preparation of the request, e.g. fetching credentials. A previous timing of ex is replaced.
+func (ex *exchange) traceTiming(request *http.Request) *http.Request {
+	ctx := request.Context()
+	if metaCollectorFrom(ctx) == nil {
+		return request
+	}
+
+	t := &timingTrace{start: time.Now()}
+	ex.timing = t
+
+	return request.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timing.DNS = time.Since(t.dnsStart)
+		},
+		ConnectStart: func(network, addr string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// parallel dials (happy eyeballs) are measured from the first one
+			if t.dialAt.IsZero() {
+				t.dialAt = time.Now()
+			}
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if err == nil {
+				t.timing.Connect = time.Since(t.dialAt)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timing.TLSHandshake = time.Since(t.tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timing.ReusedConnection = info.Reused
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timing.TimeToFirstByte = time.Since(t.start)
+		},
+	}))
+}
+
+// done records the end of the request, t can be nil.
+func (t *timingTrace) done() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.timing.Total = time.Since(t.start)
+}
+
+func (t *timingTrace) get() Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.timing
+}
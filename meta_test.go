@@ -0,0 +1,128 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetaCollector(t *testing.T) {
+	check := assert.New(t)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Server-Version", "1.2.3")
+		w.Write([]byte(`{"result":"ok","id":0}`))
+	}))
+	defer s.Close()
+
+	t.Run("metadata of the response should be collected", func(t *testing.T) {
+		rpcClient := NewClient(s.URL)
+
+		ctx, meta := WithMetaCollector(context.Background())
+		_, err := rpcClient.Call(ctx, "getBlock", 1)
+		check.Nil(err)
+
+		last, ok := meta.Last()
+		check.True(ok)
+		check.Equal(s.URL, last.Endpoint)
+		check.Equal(http.StatusOK, last.StatusCode)
+		check.Equal("1.2.3", last.Header.Get("X-Server-Version"))
+		check.Equal(len(`{"id":0,"jsonrpc":"2.0","method":"getBlock","params":[1]}`), last.RequestBytes)
+		check.Equal(len(`{"result":"ok","id":0}`), last.ResponseBytes)
+		check.False(last.Timing.ReusedConnection)
+		check.Greater(last.Timing.Connect, time.Duration(0))
+		check.Greater(last.Timing.TimeToFirstByte, time.Duration(0))
+		check.GreaterOrEqual(last.Timing.Total, last.Timing.TimeToFirstByte)
+
+		ctx, meta = WithMetaCollector(context.Background())
+		_, err = rpcClient.CallBatch(ctx, RPCRequests{NewRequest("a")})
+		check.NotNil(err) // the server does not answer with an array
+
+		last, ok = meta.Last()
+		check.True(ok)
+		check.True(last.Timing.ReusedConnection)
+		check.Zero(last.Timing.Connect)
+	})
+
+	t.Run("calls without collector should not be traced", func(t *testing.T) {
+		ex := &exchange{}
+		request := httptest.NewRequest(http.MethodPost, s.URL, nil)
+		check.Same(request, ex.traceTiming(request))
+		check.Nil(ex.timing)
+	})
+
+	t.Run("timing should not include the preparation of the request", func(t *testing.T) {
+		var requests int32
+		rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) == 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"result":"ok"}`))
+		}))
+		defer rejecting.Close()
+
+		delay := 200 * time.Millisecond
+		rpcClient := NewClientWithOpts(rejecting.URL, &RPCClientOpts{
+			HeaderProvider: func(ctx context.Context) (map[string]string, error) {
+				time.Sleep(delay)
+				return nil, nil
+			},
+			Auth: &slowRefresher{delay: delay},
+		})
+
+		ctx, meta := WithMetaCollector(context.Background())
+		_, err := rpcClient.Call(ctx, "getBlock")
+		check.Nil(err)
+
+		last, ok := meta.Last()
+		check.True(ok)
+		check.Less(last.Timing.Total, delay)
+		check.Less(last.Timing.TimeToFirstByte, delay)
+	})
+
+	t.Run("every attempt should be collected", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer failing.Close()
+
+		client := NewBalancedClient([]Endpoint{{URL: failing.URL}, {URL: s.URL}}, &BalancedClientOpts{Strategy: StrategyFailover, IdempotentMethods: []string{"getBlock"}})
+		defer client.Close()
+
+		ctx, meta := WithMetaCollector(context.Background())
+		_, err := client.Call(ctx, "getBlock")
+		check.Nil(err)
+
+		all := meta.All()
+		check.Len(all, 2)
+		check.Equal(http.StatusServiceUnavailable, all[0].StatusCode)
+		check.Equal(http.StatusOK, all[1].StatusCode)
+	})
+
+	t.Run("empty collector should return false", func(t *testing.T) {
+		_, meta := WithMetaCollector(context.Background())
+		_, ok := meta.Last()
+		check.False(ok)
+		check.Empty(meta.All())
+	})
+}
+
+// slowRefresher is an AuthProvider whose refresh takes delay, like fetching a new token
+type slowRefresher struct {
+	delay time.Duration
+}
+
+func (r *slowRefresher) Authenticate(ctx context.Context, request *http.Request, body []byte) error {
+	return nil
+}
+
+func (r *slowRefresher) Refresh(ctx context.Context, response *http.Response) error {
+	time.Sleep(r.delay)
+	return nil
+}
@@ -0,0 +1,386 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics records measurements of the calls of an RPCClient (see RPCClientOpts.Metrics).
+//
+// Implementations must be safe for concurrent use. NewPrometheusMetrics() returns a default implementation.
+type Metrics interface {
+	// RequestStarted is called when an http request (single or batch) is sent to the endpoint.
+	RequestStarted()
+
+	// RequestFinished is called when an http request that was reported by RequestStarted() is finished.
+	RequestFinished()
+
+	// ObserveCall is called for every finished JSON-RPC request, for batch requests once per request of the batch.
+	ObserveCall(observation CallObservation)
+
+	// ObserveBatchSize is called with the number of requests of every batch request.
+	ObserveBatchSize(size int)
+}
+
+// CallObservation describes a finished JSON-RPC request.
+//
+// Method: the method of the request.
+//
+// Duration: the duration of the whole call, including the time spent waiting for rate limits and queues.
+//
+// HTTPStatus: the status code of the http response, 0 if no response was received.
+//
+// RPCError: the error of the rpc response, nil if there was none.
+//
+// Err: the error returned by the call, nil if it succeeded.
+type CallObservation struct {
+	Method     string
+	Duration   time.Duration
+	HTTPStatus int
+	RPCError   *RPCError
+	Err        error
+}
+
+// metricsRecorder reports the calls of a client to Metrics.
+type metricsRecorder struct {
+	metrics Metrics
+}
+
+func newMetricsRecorder(metrics Metrics) *metricsRecorder {
+	if metrics == nil {
+		return nil
+	}
+
+	return &metricsRecorder{metrics: metrics}
+}
+
+func (r *metricsRecorder) requestStarted() {
+	if r != nil {
+		r.metrics.RequestStarted()
+	}
+}
+
+func (r *metricsRecorder) requestFinished() {
+	if r != nil {
+		r.metrics.RequestFinished()
+	}
+}
+
+func (r *metricsRecorder) observeCall(request *RPCRequest, response *RPCResponse, ex *exchange, duration time.Duration, err error) {
+	if r == nil {
+		return
+	}
+
+	var rpcErr *RPCError
+	if response != nil {
+		rpcErr = response.Error
+	}
+
+	r.metrics.ObserveCall(CallObservation{
+		Method:     request.Method,
+		Duration:   duration,
+		HTTPStatus: ex.statusCode(),
+		RPCError:   rpcErr,
+		Err:        err,
+	})
+}
+
+func (r *metricsRecorder) observeBatch(requests []*RPCRequest, responses []*RPCResponse, ex *exchange, duration time.Duration, err error) {
+	if r == nil {
+		return
+	}
+
+	r.metrics.ObserveBatchSize(len(requests))
+
+	byID := RPCResponses(responses).AsMap()
+	for _, request := range requests {
+		var rpcErr *RPCError
+		if response, ok := byID[request.ID]; ok && response != nil {
+			rpcErr = response.Error
+		}
+
+		r.metrics.ObserveCall(CallObservation{
+			Method:     request.Method,
+			Duration:   duration,
+			HTTPStatus: ex.statusCode(),
+			RPCError:   rpcErr,
+			Err:        err,
+		})
+	}
+}
+
+// DefaultLatencyBuckets are the default upper bounds of the latency histogram in seconds.
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultBatchSizeBuckets are the default upper bounds of the batch size histogram.
+var DefaultBatchSizeBuckets = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}
+
+// PrometheusMetricsOpts can be provided to NewPrometheusMetrics() to change the configuration of the metrics.
+//
+// Namespace: prefix of all metric names, defaults to "jsonrpc_client".
+//
+// LatencyBuckets: upper bounds of the latency histogram in seconds, defaults to DefaultLatencyBuckets.
+//
+// BatchSizeBuckets: upper bounds of the batch size histogram, defaults to DefaultBatchSizeBuckets.
+type PrometheusMetricsOpts struct {
+	Namespace        string
+	LatencyBuckets   []float64
+	BatchSizeBuckets []float64
+}
+
+// PrometheusMetrics is a Metrics implementation that renders its measurements in the Prometheus text exposition format.
+//
+// It implements http.Handler, so it can be registered directly as scrape endpoint:
+//
+//	metrics := jsonrpc.NewPrometheusMetrics(nil)
+//	rpcClient := jsonrpc.NewClientWithOpts(endpoint, &jsonrpc.RPCClientOpts{Metrics: metrics})
+//	http.Handle("/metrics", metrics)
+//
+// The following metrics are exported (with the default namespace):
+//
+//	jsonrpc_client_requests_total{method}                  counter
+//	jsonrpc_client_request_errors_total{method,class}      counter
+//	jsonrpc_client_http_errors_total{method,status}        counter
+//	jsonrpc_client_rpc_errors_total{method,code}           counter
+//	jsonrpc_client_request_duration_seconds{method}        histogram
+//	jsonrpc_client_in_flight_requests                      gauge
+//	jsonrpc_client_batch_size                              histogram
+//
+// PrometheusMetrics is created using the factory function NewPrometheusMetrics().
+type PrometheusMetrics struct {
+	namespace        string
+	latencyBuckets   []float64
+	batchSizeBuckets []float64
+
+	mu         sync.Mutex
+	inFlight   int
+	requests   map[string]uint64
+	errors     map[string]uint64
+	httpErrors map[string]uint64
+	rpcErrors  map[string]uint64
+	durations  map[string]*histogram
+	batchSizes *histogram
+}
+
+// NewPrometheusMetrics returns a new PrometheusMetrics.
+//
+// opts: PrometheusMetricsOpts is used to provide custom configuration, can be nil.
+func NewPrometheusMetrics(opts *PrometheusMetricsOpts) *PrometheusMetrics {
+	if opts == nil {
+		opts = &PrometheusMetricsOpts{}
+	}
+
+	m := &PrometheusMetrics{
+		namespace:        opts.Namespace,
+		latencyBuckets:   sortedBuckets(opts.LatencyBuckets, DefaultLatencyBuckets),
+		batchSizeBuckets: sortedBuckets(opts.BatchSizeBuckets, DefaultBatchSizeBuckets),
+		requests:         make(map[string]uint64),
+		errors:           make(map[string]uint64),
+		httpErrors:       make(map[string]uint64),
+		rpcErrors:        make(map[string]uint64),
+		durations:        make(map[string]*histogram),
+	}
+
+	if m.namespace == "" {
+		m.namespace = "jsonrpc_client"
+	}
+	m.batchSizes = newHistogram(m.batchSizeBuckets)
+
+	return m
+}
+
+func sortedBuckets(buckets []float64, defaults []float64) []float64 {
+	if len(buckets) == 0 {
+		buckets = defaults
+	}
+
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// RequestStarted increments the in-flight gauge.
+func (m *PrometheusMetrics) RequestStarted() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight++
+}
+
+// RequestFinished decrements the in-flight gauge.
+func (m *PrometheusMetrics) RequestFinished() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight--
+}
+
+// ObserveCall records the request count, latency and errors of a finished request.
+func (m *PrometheusMetrics) ObserveCall(o CallObservation) {
+	method := labels("method", o.Method)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[method]++
+
+	h, ok := m.durations[method]
+	if !ok {
+		h = newHistogram(m.latencyBuckets)
+		m.durations[method] = h
+	}
+	h.observe(o.Duration.Seconds())
+
+	if o.Err != nil {
+		m.errors[labels("method", o.Method, "class", ClassifyError(o.Err).String())]++
+	}
+	if o.HTTPStatus >= 400 {
+		m.httpErrors[labels("method", o.Method, "status", strconv.Itoa(o.HTTPStatus))]++
+	}
+	if o.RPCError != nil {
+		m.rpcErrors[labels("method", o.Method, "code", strconv.Itoa(o.RPCError.Code))]++
+	}
+}
+
+// ObserveBatchSize records the size of a batch request.
+func (m *PrometheusMetrics) ObserveBatchSize(size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.batchSizes.observe(float64(size))
+}
+
+// ServeHTTP writes all metrics in the Prometheus text exposition format.
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	// copy the state so that calls are not blocked while the response is written to a slow scraper
+	m.mu.Lock()
+	inFlight := m.inFlight
+	requests := maps.Clone(m.requests)
+	errors := maps.Clone(m.errors)
+	httpErrors := maps.Clone(m.httpErrors)
+	rpcErrors := maps.Clone(m.rpcErrors)
+	durations := make(map[string]*histogram, len(m.durations))
+	for key, h := range m.durations {
+		durations[key] = h.clone()
+	}
+	batchSizes := m.batchSizes.clone()
+	m.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	m.writeCounter(bw, "requests_total", "Total number of JSON-RPC requests.", requests)
+	m.writeCounter(bw, "request_errors_total", "Total number of failed JSON-RPC requests by error class.", errors)
+	m.writeCounter(bw, "http_errors_total", "Total number of JSON-RPC requests with an http error status.", httpErrors)
+	m.writeCounter(bw, "rpc_errors_total", "Total number of JSON-RPC error responses by error code.", rpcErrors)
+	m.writeHistogram(bw, "request_duration_seconds", "Duration of JSON-RPC requests in seconds.", durations)
+
+	name := m.namespace + "_in_flight_requests"
+	fmt.Fprintf(bw, "# HELP %v Number of http requests currently in flight.\n# TYPE %v gauge\n%v %v\n", name, name, name, inFlight)
+
+	m.writeHistogram(bw, "batch_size", "Number of JSON-RPC requests per batch request.", map[string]*histogram{"": batchSizes})
+	bw.Flush()
+}
+
+func (m *PrometheusMetrics) writeCounter(w *bufio.Writer, name string, help string, values map[string]uint64) {
+	name = m.namespace + "_" + name
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v counter\n", name, help, name)
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(w, "%v{%v} %v\n", name, key, values[key])
+	}
+}
+
+func (m *PrometheusMetrics) writeHistogram(w *bufio.Writer, name string, help string, values map[string]*histogram) {
+	name = m.namespace + "_" + name
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v histogram\n", name, help, name)
+	for _, key := range sortedKeys(values) {
+		h := values[key]
+		prefix := key
+		if prefix != "" {
+			prefix += ","
+		}
+
+		var cumulative uint64
+		for i, bound := range h.bounds {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "%v_bucket{%vle=\"%v\"} %v\n", name, prefix, formatFloat(bound), cumulative)
+		}
+		fmt.Fprintf(w, "%v_bucket{%vle=\"+Inf\"} %v\n", name, prefix, h.count)
+
+		suffix := ""
+		if key != "" {
+			suffix = "{" + key + "}"
+		}
+		fmt.Fprintf(w, "%v_sum%v %v\n", name, suffix, formatFloat(h.sum))
+		fmt.Fprintf(w, "%v_count%v %v\n", name, suffix, h.count)
+	}
+}
+
+// histogram counts observations in buckets with the given upper bounds.
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+// clone returns a copy of h, the bounds are shared because they never change.
+func (h *histogram) clone() *histogram {
+	c := *h
+	c.counts = append([]uint64(nil), h.counts...)
+	return &c
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// labels renders name value pairs as Prometheus label set, e.g. method="getBlock",code="-32000".
+func labels(pairs ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelValueEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+
+	return b.String()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
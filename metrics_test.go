@@ -0,0 +1,129 @@
+package jsonrpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scrape returns the text exposition of the metrics
+func scrape(t *testing.T, metrics *PrometheusMetrics) string {
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+// blockingWriter is a ResponseWriter of a slow scraper that blocks every write until release is closed
+type blockingWriter struct {
+	*httptest.ResponseRecorder
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(b []byte) (int, error) {
+	select {
+	case w.writing <- struct{}{}:
+	default:
+	}
+	<-w.release
+	return w.ResponseRecorder.Write(b)
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("calls should be recorded", func(t *testing.T) {
+		okServer := newTestServer(t, respond(http.StatusOK, `{"result":"ok","id":0}`))
+		rpcErrServer := newTestServer(t, respond(http.StatusOK, `{"error":{"code":-32000,"message":"failed"},"id":0}`))
+		batchServer := newTestServer(t, respond(http.StatusOK, `[{"result":"ok","id":0},{"error":{"code":-32601,"message":"not found"},"id":1}]`))
+		httpErrServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer httpErrServer.Close()
+
+		metrics := NewPrometheusMetrics(&PrometheusMetricsOpts{LatencyBuckets: []float64{10, 0.000001}})
+		opts := &RPCClientOpts{Metrics: metrics}
+
+		NewClientWithOpts(okServer.URL, opts).Call(context.Background(), "getBlock")
+		NewClientWithOpts(okServer.URL, opts).Call(context.Background(), "getBlock")
+		NewClientWithOpts(rpcErrServer.URL, opts).Call(context.Background(), "send")
+		NewClientWithOpts(httpErrServer.URL, opts).Call(context.Background(), "send")
+		NewClientWithOpts(batchServer.URL, opts).CallBatch(context.Background(), RPCRequests{NewRequest("getBlock"), NewRequest("unknown")})
+
+		text := scrape(t, metrics)
+		check.Contains(text, "# TYPE jsonrpc_client_requests_total counter\n")
+		check.Contains(text, `jsonrpc_client_requests_total{method="getBlock"} 3`+"\n")
+		check.Contains(text, `jsonrpc_client_requests_total{method="send"} 2`+"\n")
+		check.Contains(text, `jsonrpc_client_requests_total{method="unknown"} 1`+"\n")
+		check.Contains(text, `jsonrpc_client_request_errors_total{method="send",class="http"} 1`+"\n")
+		check.Contains(text, `jsonrpc_client_http_errors_total{method="send",status="503"} 1`+"\n")
+		check.Contains(text, `jsonrpc_client_rpc_errors_total{method="send",code="-32000"} 1`+"\n")
+		check.Contains(text, `jsonrpc_client_rpc_errors_total{method="unknown",code="-32601"} 1`+"\n")
+		check.Contains(text, `jsonrpc_client_request_duration_seconds_bucket{method="getBlock",le="1e-06"} 0`+"\n")
+		check.Contains(text, `jsonrpc_client_request_duration_seconds_bucket{method="getBlock",le="10"} 3`+"\n")
+		check.Contains(text, `jsonrpc_client_request_duration_seconds_bucket{method="getBlock",le="+Inf"} 3`+"\n")
+		check.Contains(text, `jsonrpc_client_request_duration_seconds_count{method="getBlock"} 3`+"\n")
+		check.Contains(text, "jsonrpc_client_in_flight_requests 0\n")
+		check.Contains(text, `jsonrpc_client_batch_size_bucket{le="1"} 0`+"\n")
+		check.Contains(text, `jsonrpc_client_batch_size_bucket{le="2"} 1`+"\n")
+		check.Contains(text, "jsonrpc_client_batch_size_sum 2\n")
+		check.Contains(text, "jsonrpc_client_batch_size_count 1\n")
+	})
+
+	t.Run("in-flight requests should be tracked", func(t *testing.T) {
+		var canceled int32
+		s := newTestServer(t, slow(time.Second, &canceled))
+		metrics := NewPrometheusMetrics(&PrometheusMetricsOpts{Namespace: "rpc"})
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Metrics: metrics})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			rpcClient.Call(ctx, "slow")
+			close(done)
+		}()
+
+		check.Eventually(func() bool {
+			return strings.Contains(scrape(t, metrics), "rpc_in_flight_requests 1\n")
+		}, time.Second, time.Millisecond)
+
+		cancel()
+		<-done
+		check.Contains(scrape(t, metrics), "rpc_in_flight_requests 0\n")
+		check.Contains(scrape(t, metrics), `rpc_request_errors_total{method="slow",class="canceled"} 1`)
+	})
+
+	t.Run("slow scrapers should not block calls", func(t *testing.T) {
+		metrics := NewPrometheusMetrics(nil)
+		w := &blockingWriter{ResponseRecorder: httptest.NewRecorder(), writing: make(chan struct{}, 1), release: make(chan struct{})}
+		go metrics.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		<-w.writing
+
+		observed := make(chan struct{})
+		go func() {
+			metrics.ObserveCall(CallObservation{Method: "getBlock", Duration: time.Millisecond})
+			close(observed)
+		}()
+
+		select {
+		case <-observed:
+		case <-time.After(time.Second):
+			t.Error("call was blocked by the scrape")
+		}
+		close(w.release)
+	})
+
+	t.Run("label values should be escaped", func(t *testing.T) {
+		check.Equal(`method="a\"b\\c\nd"`, labels("method", "a\"b\\c\nd"))
+	})
+}
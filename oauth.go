@@ -0,0 +1,259 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClientCredentialsOpts can be provided to NewClientCredentials() to change the configuration of the token source.
+//
+// Scopes: scopes that are requested, sent space separated in the scope parameter.
+//
+// EndpointParams: additional parameters of the token request, e.g. "audience".
+//
+// CredentialsInBody: if true, client id and secret are sent as form parameters instead of basic auth.
+//
+// HTTPClient: client that is used for token requests, defaults to http.DefaultClient.
+//
+// ExpiryDelta: a token is refreshed this long before it expires, defaults to 10 seconds.
+//
+// Timeout: maximum duration of a token request, defaults to 30 seconds.
+// A token request is shared by all calls waiting for it, so it is not canceled with the context of any of them.
+type ClientCredentialsOpts struct {
+	Scopes            []string
+	EndpointParams    map[string]string
+	CredentialsInBody bool
+	HTTPClient        HTTPClient
+	ExpiryDelta       time.Duration
+	Timeout           time.Duration
+}
+
+// ClientCredentials is an AuthProvider that authenticates requests with an OAuth2 access token
+// obtained with the client credentials grant (RFC 6749, section 4.4).
+//
+// The token is cached until shortly before it expires. Only one token request is made at a time,
+// concurrent calls wait for it until their context is done. If the server rejects a token with 401 Unauthorized,
+// a new token is requested and the call is sent once more.
+//
+// e.g.
+//
+//	auth := jsonrpc.NewClientCredentials("https://auth.example.com/oauth/token", "client-id", "secret", nil)
+//	rpcClient := jsonrpc.NewClientWithOpts(endpoint, &jsonrpc.RPCClientOpts{Auth: auth})
+//
+// ClientCredentials is created using the factory function NewClientCredentials().
+type ClientCredentials struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	opts         ClientCredentialsOpts
+	now          func() time.Time
+
+	mu       sync.Mutex
+	token    *oauthToken
+	fetching *tokenFetch
+}
+
+// tokenFetch is a token request that is shared by all calls waiting for a token.
+type tokenFetch struct {
+	done  chan struct{}
+	token *oauthToken
+	err   error
+}
+
+type oauthToken struct {
+	AccessToken string      `json:"access_token"`
+	TokenType   string      `json:"token_type"`
+	ExpiresIn   json.Number `json:"expires_in"`
+
+	expiry time.Time
+}
+
+type oauthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// NewClientCredentials returns a new ClientCredentials token source.
+//
+// tokenURL: the token endpoint of the authorization server.
+//
+// clientID, clientSecret: the credentials of the client.
+//
+// opts: ClientCredentialsOpts is used to provide custom configuration, can be nil.
+func NewClientCredentials(tokenURL string, clientID string, clientSecret string, opts *ClientCredentialsOpts) *ClientCredentials {
+	c := &ClientCredentials{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		now:          time.Now,
+	}
+
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.HTTPClient == nil {
+		c.opts.HTTPClient = http.DefaultClient
+	}
+	if c.opts.ExpiryDelta <= 0 {
+		c.opts.ExpiryDelta = 10 * time.Second
+	}
+	if c.opts.Timeout <= 0 {
+		c.opts.Timeout = 30 * time.Second
+	}
+
+	return c
+}
+
+// Token returns the cached access token or requests a new one if there is none or it is about to expire.
+func (c *ClientCredentials) Token(ctx context.Context) (string, error) {
+	token, err := c.current(ctx, "")
+	if err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// Authenticate sets the Authorization header of the request to the access token.
+func (c *ClientCredentials) Authenticate(ctx context.Context, request *http.Request, body []byte) error {
+	token, err := c.current(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Authorization", token.authorization())
+	return nil
+}
+
+// Refresh discards the token that was rejected by the server and requests a new one.
+// If another call already replaced the rejected token, the new one is kept.
+func (c *ClientCredentials) Refresh(ctx context.Context, response *http.Response) error {
+	rejected := ""
+	if response != nil && response.Request != nil {
+		rejected = response.Request.Header.Get("Authorization")
+	}
+
+	_, err := c.current(ctx, rejected)
+	return err
+}
+
+// current returns the cached token or requests a new one. The cached token is only used if it is not
+// about to expire and its Authorization header differs from rejected.
+func (c *ClientCredentials) current(ctx context.Context, rejected string) (*oauthToken, error) {
+	c.mu.Lock()
+	if token := c.token; token != nil && token.authorization() != rejected && (token.expiry.IsZero() || c.now().Before(token.expiry)) {
+		c.mu.Unlock()
+		return token, nil
+	}
+
+	fetch := c.fetching
+	if fetch == nil {
+		fetch = &tokenFetch{done: make(chan struct{})}
+		c.fetching = fetch
+		go c.fetch(context.WithoutCancel(ctx), fetch)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-fetch.done:
+		return fetch.token, fetch.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("oauth token request to %v: %w", redactURL(c.tokenURL), ctx.Err())
+	}
+}
+
+// fetch requests a new token for all calls waiting for fetch and caches it.
+func (c *ClientCredentials) fetch(ctx context.Context, fetch *tokenFetch) {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+
+	fetch.token, fetch.err = c.requestToken(ctx)
+
+	c.mu.Lock()
+	if fetch.err == nil {
+		c.token = fetch.token
+	}
+	c.fetching = nil
+	c.mu.Unlock()
+
+	close(fetch.done)
+}
+
+// requestToken requests a new token from the token endpoint.
+func (c *ClientCredentials) requestToken(ctx context.Context) (*oauthToken, error) {
+	params := url.Values{}
+	params.Set("grant_type", "client_credentials")
+	if len(c.opts.Scopes) > 0 {
+		params.Set("scope", strings.Join(c.opts.Scopes, " "))
+	}
+	for k, v := range c.opts.EndpointParams {
+		params.Set(k, v)
+	}
+	if c.opts.CredentialsInBody {
+		params.Set("client_id", c.clientID)
+		params.Set("client_secret", c.clientSecret)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "POST", c.tokenURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("oauth token request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if !c.opts.CredentialsInBody {
+		request.SetBasicAuth(url.QueryEscape(c.clientID), url.QueryEscape(c.clientSecret))
+	}
+
+	start := c.now()
+	response, err := c.opts.HTTPClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("oauth token request to %v: %w", request.URL.Redacted(), &classifiedError{ErrorClassNetwork, err})
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("oauth token request to %v: %w", request.URL.Redacted(), &classifiedError{ErrorClassNetwork, err})
+	}
+
+	if response.StatusCode != http.StatusOK {
+		var oauthErr oauthError
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error != "" {
+			return nil, fmt.Errorf("oauth token request to %v status code: %v: %v %v", request.URL.Redacted(), response.StatusCode, oauthErr.Error, oauthErr.Description)
+		}
+		return nil, fmt.Errorf("oauth token request to %v status code: %v", request.URL.Redacted(), response.StatusCode)
+	}
+
+	var token oauthToken
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("oauth token request to %v: could not decode token: %w", request.URL.Redacted(), err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("oauth token request to %v: response contains no access_token", request.URL.Redacted())
+	}
+
+	if token.ExpiresIn != "" {
+		seconds, err := strconv.ParseInt(string(token.ExpiresIn), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("oauth token request to %v: invalid expires_in: %w", request.URL.Redacted(), err)
+		}
+		token.expiry = start.Add(time.Duration(seconds)*time.Second - c.opts.ExpiryDelta)
+	}
+
+	return &token, nil
+}
+
+// authorization returns the value of the Authorization header, token types are case-insensitive.
+func (t *oauthToken) authorization() string {
+	if t.TokenType == "" || strings.EqualFold(t.TokenType, "bearer") {
+		return "Bearer " + t.AccessToken
+	}
+	return t.TokenType + " " + t.AccessToken
+}
@@ -0,0 +1,212 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// issueTokens issues tokens "token-1", "token-2", ... with the client credentials grant
+func issueTokens(expiresIn string) testHandler {
+	var issued int64
+	return func(w http.ResponseWriter, r *http.Request, body []byte) {
+		form, _ := url.ParseQuery(string(body))
+		if id, secret, _ := r.BasicAuth(); form.Get("client_id") == "" && (id != "client" || secret != "s%C3%A9cret") {
+			respond(http.StatusUnauthorized, `{"error":"invalid_client","error_description":"bad credentials"}`)(w, r, body)
+			return
+		}
+
+		n := atomic.AddInt64(&issued, 1)
+		time.Sleep(10 * time.Millisecond)
+		fmt.Fprintf(w, `{"access_token":"token-%v","token_type":"bearer","expires_in":%v}`, n, expiresIn)
+	}
+}
+
+// protect accepts requests with the Authorization header stored in valid
+func protect(valid *atomic.Value) testHandler {
+	return func(w http.ResponseWriter, r *http.Request, body []byte) {
+		if r.Header.Get("Authorization") != valid.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"result":"ok"}`))
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("token should be requested once and cached", func(t *testing.T) {
+		tokens := newTestServer(t, issueTokens("3600"))
+		auth := NewClientCredentials(tokens.URL, "client", "sécret", &ClientCredentialsOpts{
+			Scopes:         []string{"read", "write"},
+			EndpointParams: map[string]string{"audience": "rpc"},
+		})
+
+		var valid atomic.Value
+		valid.Store("Bearer token-1")
+		s := newTestServer(t, protect(&valid))
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Auth: auth})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := rpcClient.Call(context.Background(), "getBlock")
+				check.Nil(err)
+				check.Equal("ok", res.Result)
+			}()
+		}
+		wg.Wait()
+
+		check.Equal(1, tokens.requests())
+		form, _ := url.ParseQuery(<-tokens.bodies)
+		check.Equal("client_credentials", form.Get("grant_type"))
+		check.Equal("read write", form.Get("scope"))
+		check.Equal("rpc", form.Get("audience"))
+		check.Equal("application/x-www-form-urlencoded", (<-tokens.headers).Get("Content-Type"))
+
+		token, err := auth.Token(context.Background())
+		check.Nil(err)
+		check.Equal("token-1", token)
+	})
+
+	t.Run("token should be refreshed before it expires", func(t *testing.T) {
+		tokens := newTestServer(t, issueTokens(`"60"`))
+		auth := NewClientCredentials(tokens.URL, "client", "sécret", &ClientCredentialsOpts{ExpiryDelta: 10 * time.Second})
+		now := time.Now()
+		auth.now = func() time.Time { return now }
+
+		token, err := auth.Token(context.Background())
+		check.Nil(err)
+		check.Equal("token-1", token)
+
+		now = now.Add(45 * time.Second)
+		token, _ = auth.Token(context.Background())
+		check.Equal("token-1", token)
+
+		now = now.Add(10 * time.Second)
+		token, _ = auth.Token(context.Background())
+		check.Equal("token-2", token)
+	})
+
+	t.Run("401 should retry once with a fresh token", func(t *testing.T) {
+		tokens := newTestServer(t, issueTokens("3600"))
+		auth := NewClientCredentials(tokens.URL, "client", "sécret", nil)
+
+		var valid atomic.Value
+		valid.Store("Bearer token-1")
+		s := newTestServer(t, protect(&valid))
+
+		var retries []*RetryEvent
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{
+			Auth: auth,
+			Hooks: &Hooks{OnRetry: func(ctx context.Context, event *RetryEvent) {
+				retries = append(retries, event)
+			}},
+		})
+
+		_, err := rpcClient.Call(context.Background(), "getBlock")
+		check.Nil(err)
+
+		// the server revokes token-1
+		valid.Store("Bearer token-2")
+		res, err := rpcClient.Call(context.Background(), "getBlock")
+		check.Nil(err)
+		check.Equal("ok", res.Result)
+		check.Equal(2, tokens.requests())
+		check.Equal(3, s.requests())
+		if check.Len(retries, 1) {
+			check.Equal("getBlock", retries[0].Method)
+			check.Equal(ErrorClassHTTP, retries[0].ErrorClass)
+		}
+
+		// a token that is rejected again is not retried twice
+		valid.Store("Bearer other")
+		_, err = rpcClient.CallBatch(context.Background(), RPCRequests{NewRequest("getBlock")})
+		check.Equal(ErrorClassHTTP, ClassifyError(err))
+		check.Equal(5, s.requests())
+		check.Equal(3, tokens.requests())
+	})
+
+	t.Run("token endpoint errors should fail the call", func(t *testing.T) {
+		tokens := newTestServer(t, issueTokens("3600"))
+		auth := NewClientCredentials(tokens.URL, "client", "wrong", nil)
+
+		var valid atomic.Value
+		valid.Store("")
+		s := newTestServer(t, protect(&valid))
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Auth: auth})
+
+		_, err := rpcClient.Call(context.Background(), "getBlock")
+		check.ErrorContains(err, "status code: 401: invalid_client bad credentials")
+		check.Equal(0, s.requests())
+	})
+
+	t.Run("waiting calls should honor their own context", func(t *testing.T) {
+		release := make(chan struct{})
+		issue := issueTokens("3600")
+		tokens := newTestServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+			<-release
+			issue(w, r, body)
+		})
+		auth := NewClientCredentials(tokens.URL, "client", "sécret", nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error, 1)
+		go func() {
+			_, err := auth.Token(ctx)
+			errs <- err
+		}()
+		check.Eventually(func() bool { return tokens.requests() == 1 }, time.Second, time.Millisecond)
+
+		results := make(chan string, 1)
+		go func() {
+			token, _ := auth.Token(context.Background())
+			results <- token
+		}()
+
+		// the call that started the token request gives up, the request goes on for the other call
+		cancel()
+		check.ErrorIs(<-errs, context.Canceled)
+
+		short, cancelShort := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancelShort()
+		_, err := auth.Token(short)
+		check.ErrorIs(err, context.DeadlineExceeded)
+
+		close(release)
+		check.Equal("token-1", <-results)
+		check.Equal(1, tokens.requests())
+	})
+
+	t.Run("token requests should time out", func(t *testing.T) {
+		tokens := newTestServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+			<-r.Context().Done()
+		})
+		auth := NewClientCredentials(tokens.URL, "client", "sécret", &ClientCredentialsOpts{Timeout: 20 * time.Millisecond})
+
+		_, err := auth.Token(context.Background())
+		check.Equal(ErrorClassTimeout, ClassifyError(err))
+	})
+
+	t.Run("credentials can be sent in the body", func(t *testing.T) {
+		tokens := newTestServer(t, issueTokens("3600"))
+		auth := NewClientCredentials(tokens.URL, "client", "sécret", &ClientCredentialsOpts{CredentialsInBody: true})
+
+		_, err := auth.Token(context.Background())
+		check.Nil(err)
+		form, _ := url.ParseQuery(<-tokens.bodies)
+		check.Equal("client", form.Get("client_id"))
+		check.Equal("sécret", form.Get("client_secret"))
+		check.Empty((<-tokens.headers).Get("Authorization"))
+	})
+}
@@ -0,0 +1,378 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned if a call exceeds the client side rate limit and RateLimitOpts.FailFast is set.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// ErrBatchExceedsBurst is returned if RateLimitOpts.FailFast is set and a batch request contains more requests
+// than the Burst of its limit, so that it could never be sent.
+var ErrBatchExceedsBurst = errors.New("batch exceeds burst")
+
+// RateLimit defines a token bucket that allows Rate requests per second on average and bursts of up to Burst requests.
+//
+// Rate: requests per second, 0 means unlimited.
+//
+// Burst: maximum number of requests that can be sent at once, defaults to Rate rounded up (at least 1).
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitOpts can be provided with RPCClientOpts.RateLimit to limit the rate of requests sent by the client.
+//
+// RateLimit: the limit that applies to all methods without an own limit.
+//
+// Methods: per method limits that are used instead of the global limit.
+// A method limit with Rate 0 exempts the method from rate limiting.
+//
+// FailFast: if true, calls that exceed the limit return ErrRateLimited immediately.
+// Batch requests with more requests than the Burst of their limit are rejected with ErrBatchExceedsBurst.
+// Otherwise calls wait until they are allowed or their context is done, large batches wait until the bucket has refilled.
+//
+// Every request in a batch request counts as one request of its method.
+type RateLimitOpts struct {
+	RateLimit
+	Methods  map[string]RateLimit
+	FailFast bool
+}
+
+type rateLimiter struct {
+	global   *tokenBucket
+	methods  map[string]*tokenBucket
+	failFast bool
+}
+
+func newRateLimiter(opts *RateLimitOpts) *rateLimiter {
+	if opts == nil {
+		return nil
+	}
+
+	limiter := &rateLimiter{
+		global:   newTokenBucket(opts.RateLimit),
+		methods:  make(map[string]*tokenBucket),
+		failFast: opts.FailFast,
+	}
+
+	for method, limit := range opts.Methods {
+		limiter.methods[method] = newTokenBucket(limit)
+	}
+
+	return limiter
+}
+
+// wait blocks until all given requests are allowed to be sent.
+//
+// units maps a method to the number of requests of that method.
+func (l *rateLimiter) wait(ctx context.Context, units map[string]int) error {
+	if l == nil {
+		return nil
+	}
+
+	buckets := make(map[*tokenBucket]float64)
+	for method, n := range units {
+		bucket, ok := l.methods[method]
+		if !ok {
+			bucket = l.global
+		}
+		if bucket != nil {
+			buckets[bucket] += float64(n)
+		}
+	}
+
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+
+	if l.failFast {
+		for bucket, n := range buckets {
+			if n > bucket.burst {
+				return ErrBatchExceedsBurst
+			}
+		}
+
+		taken := make(map[*tokenBucket]float64, len(buckets))
+		for bucket, n := range buckets {
+			if !bucket.take(n, now) {
+				for b, n := range taken {
+					b.cancel(n)
+				}
+				return ErrRateLimited
+			}
+			taken[bucket] = n
+		}
+		return nil
+	}
+
+	var delay time.Duration
+	for bucket, n := range buckets {
+		if d := bucket.reserve(n, now); d > delay {
+			delay = d
+		}
+	}
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		for bucket, n := range buckets {
+			bucket.cancel(n)
+		}
+		return ctx.Err()
+	}
+}
+
+// tokenBucket is a token bucket that allows the number of tokens to become negative,
+// so that reservations are served in order.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	if limit.Rate <= 0 {
+		return nil
+	}
+
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = math.Max(1, math.Ceil(limit.Rate))
+	}
+
+	return &tokenBucket{
+		rate:   limit.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+// take removes n tokens if they are available.
+func (b *tokenBucket) take(n float64, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	if b.tokens < n {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+// reserve removes n tokens and returns the time to wait until they are available.
+func (b *tokenBucket) reserve(n float64, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns n tokens that were taken or reserved but not used.
+func (b *tokenBucket) cancel(n float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = math.Min(b.burst, b.tokens+n)
+}
+
+// batchUnits counts the requests per method of a batch request.
+func batchUnits(requests []*RPCRequest) map[string]int {
+	units := make(map[string]int)
+	for _, req := range requests {
+		if req != nil {
+			units[req.Method]++
+		}
+	}
+
+	return units
+}
+
+// RateLimitInfo holds the rate limit information a server sent in its response headers.
+//
+// RetryAfter: parsed from the Retry-After header (delay in seconds or HTTP date), 0 if missing.
+//
+// Limit: parsed from the X-RateLimit-Limit header, -1 if missing.
+//
+// Remaining: parsed from the X-RateLimit-Remaining header, -1 if missing.
+//
+// Reset: point in time when the quota is reset. It is derived from Retry-After or X-RateLimit-Reset
+// (unix timestamp in seconds or milliseconds, or delay in seconds), zero if unknown.
+type RateLimitInfo struct {
+	RetryAfter time.Duration
+	Limit      int
+	Remaining  int
+	Reset      time.Time
+}
+
+// parseRateLimitInfo extracts the rate limit headers of the response, nil if none of them is present.
+func parseRateLimitInfo(response *http.Response, now time.Time) *RateLimitInfo {
+	info := &RateLimitInfo{
+		Limit:     parseIntHeader(response.Header, "X-RateLimit-Limit"),
+		Remaining: parseIntHeader(response.Header, "X-RateLimit-Remaining"),
+	}
+	found := info.Limit >= 0 || info.Remaining >= 0
+
+	if reset := strings.TrimSpace(response.Header.Get("X-RateLimit-Reset")); reset != "" {
+		if value, err := strconv.ParseFloat(reset, 64); err == nil && value >= 0 {
+			found = true
+			// large values are unix timestamps in seconds or milliseconds, small values are delays in seconds
+			switch {
+			case value > 1e12:
+				info.Reset = time.Unix(0, int64(value*float64(time.Millisecond)))
+			case value > 1e9:
+				info.Reset = time.Unix(0, int64(value*float64(time.Second)))
+			default:
+				info.Reset = now.Add(time.Duration(value * float64(time.Second)))
+			}
+		}
+	}
+
+	if retryAfter, ok := parseRetryAfter(response.Header.Get("Retry-After"), now); ok {
+		found = true
+		info.RetryAfter = retryAfter
+		if info.Reset.IsZero() {
+			info.Reset = now.Add(retryAfter)
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	return info
+}
+
+// parseRetryAfter parses the value of a Retry-After header that is either a delay in seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	if date.Before(now) {
+		return 0, true
+	}
+
+	return date.Sub(now), true
+}
+
+func parseIntHeader(header http.Header, key string) int {
+	value, err := strconv.Atoi(strings.TrimSpace(header.Get(key)))
+	if err != nil || value < 0 {
+		return -1
+	}
+
+	return value
+}
+
+// DefaultMaxPause is the default of RPCClientOpts.MaxPause.
+const DefaultMaxPause = 5 * time.Minute
+
+// endpointPause blocks calls to an endpoint until the reset time announced by the server.
+type endpointPause struct {
+	max time.Duration
+
+	mu    sync.Mutex
+	until time.Time
+}
+
+func newEndpointPause(max time.Duration) *endpointPause {
+	if max <= 0 {
+		max = DefaultMaxPause
+	}
+
+	return &endpointPause{max: max}
+}
+
+// update pauses the endpoint if the server asked to retry later or the quota is exhausted.
+// The pause never lasts longer than max, so that a bogus reset time can not block the endpoint forever.
+func (p *endpointPause) update(statusCode int, info *RateLimitInfo) {
+	if p == nil || info == nil || info.Reset.IsZero() {
+		return
+	}
+
+	retryLater := info.RetryAfter > 0 && (statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable)
+	if !retryLater && info.Remaining != 0 {
+		return
+	}
+
+	until := info.Reset
+	if limit := time.Now().Add(p.max); until.After(limit) {
+		until = limit
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if until.After(p.until) {
+		p.until = until
+	}
+}
+
+// wait blocks until the endpoint is no longer paused or the context is done.
+func (p *endpointPause) wait(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	delay := time.Until(p.until)
+	p.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
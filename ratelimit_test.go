@@ -0,0 +1,259 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("fail fast should return ErrRateLimited", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":1}`))
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{
+			RateLimit: &RateLimitOpts{RateLimit: RateLimit{Rate: 1, Burst: 2}, FailFast: true},
+		})
+
+		_, err := rpcClient.Call(context.Background(), "something")
+		check.Nil(err)
+		_, err = rpcClient.Call(context.Background(), "something")
+		check.Nil(err)
+		_, err = rpcClient.Call(context.Background(), "something")
+		check.True(errors.Is(err, ErrRateLimited))
+		check.Equal(2, s.requests())
+	})
+
+	t.Run("calls should wait for tokens", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":1}`))
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{
+			RateLimit: &RateLimitOpts{RateLimit: RateLimit{Rate: 20, Burst: 1}},
+		})
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			_, err := rpcClient.Call(context.Background(), "something")
+			check.Nil(err)
+		}
+		check.GreaterOrEqual(time.Since(start), 90*time.Millisecond)
+		check.Equal(3, s.requests())
+	})
+
+	t.Run("waiting should respect context cancellation", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":1}`))
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{
+			RateLimit: &RateLimitOpts{RateLimit: RateLimit{Rate: 0.1, Burst: 1}},
+		})
+
+		_, err := rpcClient.Call(context.Background(), "something")
+		check.Nil(err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err = rpcClient.Call(ctx, "something")
+		check.True(errors.Is(err, context.DeadlineExceeded))
+		check.Less(time.Since(start), time.Second)
+		check.Equal(1, s.requests())
+	})
+
+	t.Run("method limits should override the global limit", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":1}`))
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{
+			RateLimit: &RateLimitOpts{
+				RateLimit: RateLimit{Rate: 1, Burst: 1},
+				Methods: map[string]RateLimit{
+					"unlimited": {},
+					"limited":   {Rate: 1, Burst: 2},
+				},
+				FailFast: true,
+			},
+		})
+
+		_, err := rpcClient.Call(context.Background(), "something")
+		check.Nil(err)
+		_, err = rpcClient.Call(context.Background(), "something")
+		check.ErrorIs(err, ErrRateLimited)
+
+		for i := 0; i < 5; i++ {
+			_, err = rpcClient.Call(context.Background(), "unlimited")
+			check.Nil(err)
+		}
+
+		_, err = rpcClient.Call(context.Background(), "limited")
+		check.Nil(err)
+		_, err = rpcClient.Call(context.Background(), "limited")
+		check.Nil(err)
+		_, err = rpcClient.Call(context.Background(), "limited")
+		check.ErrorIs(err, ErrRateLimited)
+	})
+
+	t.Run("batch should count every request", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `[{"result":1,"id":0}]`))
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{
+			RateLimit: &RateLimitOpts{RateLimit: RateLimit{Rate: 1, Burst: 3}, FailFast: true},
+		})
+
+		_, err := rpcClient.CallBatch(context.Background(), RPCRequests{
+			NewRequest("a"), NewRequest("b"), NewRequest("c"), NewRequest("d"),
+		})
+		// the batch can never be sent, no matter how long it waits
+		check.ErrorIs(err, ErrBatchExceedsBurst)
+		check.Equal(ErrorClassRejected, ClassifyError(err))
+
+		_, err = rpcClient.CallBatch(context.Background(), RPCRequests{
+			NewRequest("a"), NewRequest("b"), NewRequest("c"),
+		})
+		check.Nil(err)
+
+		_, err = rpcClient.Call(context.Background(), "a")
+		check.ErrorIs(err, ErrRateLimited)
+		check.Equal(1, s.requests())
+	})
+
+	t.Run("balanced client should share the limit between endpoints", func(t *testing.T) {
+		s1 := newTestServer(t, respond(http.StatusOK, `{"result":1}`))
+		s2 := newTestServer(t, respond(http.StatusOK, `{"result":2}`))
+		s3 := newTestServer(t, respond(http.StatusOK, `{"result":3}`))
+		client := NewBalancedClient([]Endpoint{{URL: s1.URL}, {URL: s2.URL}, {URL: s3.URL}}, &BalancedClientOpts{
+			ClientOpts: &RPCClientOpts{
+				RateLimit: &RateLimitOpts{RateLimit: RateLimit{Rate: 0.001, Burst: 1}, FailFast: true},
+			},
+		})
+
+		admitted := 0
+		for i := 0; i < 6; i++ {
+			if _, err := client.Call(context.Background(), "a"); err == nil {
+				admitted++
+			} else {
+				check.ErrorIs(err, ErrRateLimited)
+			}
+		}
+		check.Equal(1, admitted)
+		check.Equal(1, s1.requests()+s2.requests()+s3.requests())
+	})
+}
+
+func TestParseRateLimitInfo(t *testing.T) {
+	check := assert.New(t)
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	response := func(headers map[string]string) *http.Response {
+		res := &http.Response{Header: make(http.Header)}
+		for k, v := range headers {
+			res.Header.Set(k, v)
+		}
+		return res
+	}
+
+	check.Nil(parseRateLimitInfo(response(nil), now))
+	check.Nil(parseRateLimitInfo(response(map[string]string{"Retry-After": "soon"}), now))
+
+	info := parseRateLimitInfo(response(map[string]string{"Retry-After": "30"}), now)
+	check.Equal(30*time.Second, info.RetryAfter)
+	check.Equal(now.Add(30*time.Second), info.Reset)
+	check.Equal(-1, info.Remaining)
+	check.Equal(-1, info.Limit)
+
+	info = parseRateLimitInfo(response(map[string]string{"Retry-After": "Sun, 01 Jan 2023 12:01:00 GMT"}), now)
+	check.Equal(time.Minute, info.RetryAfter)
+
+	info = parseRateLimitInfo(response(map[string]string{
+		"X-RateLimit-Limit":     "100",
+		"X-RateLimit-Remaining": "0",
+		"X-RateLimit-Reset":     "1672574460",
+	}), now)
+	check.Equal(100, info.Limit)
+	check.Equal(0, info.Remaining)
+	check.True(now.Add(time.Minute).Equal(info.Reset))
+
+	info = parseRateLimitInfo(response(map[string]string{"X-RateLimit-Reset": "1672574460000"}), now)
+	check.True(now.Add(time.Minute).Equal(info.Reset))
+
+	info = parseRateLimitInfo(response(map[string]string{"X-RateLimit-Reset": "5"}), now)
+	check.Equal(now.Add(5*time.Second), info.Reset)
+}
+
+func TestRespectRetryAfter(t *testing.T) {
+	check := assert.New(t)
+
+	// limitOnce answers the first request with status and headers and all others with status 200
+	limitOnce := func(headers map[string]string, status int) testHandler {
+		var requests int32
+		return func(w http.ResponseWriter, r *http.Request, body []byte) {
+			if atomic.AddInt32(&requests, 1) == 1 {
+				for k, v := range headers {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(status)
+			}
+			w.Write([]byte(`{"result":1}`))
+		}
+	}
+
+	t.Run("http error should expose rate limit headers", func(t *testing.T) {
+		s := newTestServer(t, limitOnce(map[string]string{"Retry-After": "120", "X-RateLimit-Remaining": "0"}, http.StatusTooManyRequests))
+		rpcClient := NewClient(s.URL)
+
+		_, err := rpcClient.Call(context.Background(), "something")
+		var httpErr *HTTPError
+		check.True(errors.As(err, &httpErr))
+		check.Equal(http.StatusTooManyRequests, httpErr.Code)
+		check.NotNil(httpErr.RateLimit)
+		check.Equal(120*time.Second, httpErr.RateLimit.RetryAfter)
+		check.Equal(0, httpErr.RateLimit.Remaining)
+
+		// without RespectRetryAfter the next call is sent immediately
+		_, err = rpcClient.Call(context.Background(), "something")
+		check.Nil(err)
+		check.Equal(2, s.requests())
+	})
+
+	t.Run("client should pause after retry after", func(t *testing.T) {
+		s := newTestServer(t, limitOnce(map[string]string{"Retry-After": "120"}, http.StatusServiceUnavailable))
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{RespectRetryAfter: true})
+
+		_, err := rpcClient.Call(context.Background(), "something")
+		check.NotNil(err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err = rpcClient.CallBatch(ctx, RPCRequests{NewRequest("something")})
+		check.ErrorIs(err, context.DeadlineExceeded)
+		check.Equal(1, s.requests())
+	})
+
+	t.Run("client should pause until exhausted quota is reset", func(t *testing.T) {
+		s := newTestServer(t, limitOnce(map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "0.2"}, http.StatusOK))
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{RespectRetryAfter: true})
+
+		_, err := rpcClient.Call(context.Background(), "something")
+		check.Nil(err)
+
+		start := time.Now()
+		_, err = rpcClient.Call(context.Background(), "something")
+		check.Nil(err)
+		check.GreaterOrEqual(time.Since(start), 150*time.Millisecond)
+		check.Equal(2, s.requests())
+	})
+
+	t.Run("pause should not exceed max pause", func(t *testing.T) {
+		s := newTestServer(t, limitOnce(map[string]string{"Retry-After": "3600"}, http.StatusTooManyRequests))
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{RespectRetryAfter: true, MaxPause: 200 * time.Millisecond})
+
+		_, err := rpcClient.Call(context.Background(), "something")
+		check.NotNil(err)
+
+		start := time.Now()
+		_, err = rpcClient.Call(context.Background(), "something")
+		check.Nil(err)
+		check.GreaterOrEqual(time.Since(start), 150*time.Millisecond)
+		check.Less(time.Since(start), 2*time.Second)
+		check.Equal(2, s.requests())
+	})
+}
@@ -0,0 +1,412 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoRecording is returned by a ReplayClient if no recording matches a request.
+var ErrNoRecording = errors.New("no matching recording")
+
+// Recording is a recorded exchange of an http request and its response, as written by a Recorder.
+//
+// Time: the time the request was sent.
+//
+// Duration: the time until the response body was read.
+//
+// Error: the error of the http client if no response was received, StatusCode, ResponseHeader and ResponseBody are empty then.
+type Recording struct {
+	Time           time.Time     `json:"time"`
+	Duration       time.Duration `json:"duration"`
+	URL            string        `json:"url"`
+	RequestHeader  http.Header   `json:"requestHeader"`
+	RequestBody    string        `json:"requestBody"`
+	StatusCode     int           `json:"statusCode,omitempty"`
+	ResponseHeader http.Header   `json:"responseHeader,omitempty"`
+	ResponseBody   string        `json:"responseBody,omitempty"`
+	Error          string        `json:"error,omitempty"`
+}
+
+// RecorderOpts can be provided to NewRecorder() to change the configuration of the recorder.
+//
+// HTTPClient: the http client that sends the requests, defaults to http.Client.
+//
+// RedactHeaders: request and response headers whose values are not recorded.
+// Authorization, Proxy-Authorization, Cookie and Set-Cookie are always redacted.
+type RecorderOpts struct {
+	HTTPClient    HTTPClient
+	RedactHeaders []string
+}
+
+// Recorder is an HTTPClient that records every request and response as Recording to a JSONL stream.
+// Use it as RPCClientOpts.HTTPClient and replay the recordings with a ReplayClient.
+//
+//	file, _ := os.Create("traffic.jsonl")
+//	recorder := jsonrpc.NewRecorder(file, nil)
+//	rpcClient := jsonrpc.NewClientWithOpts(endpoint, &jsonrpc.RPCClientOpts{HTTPClient: recorder})
+//
+// Recorder is created using the factory function NewRecorder().
+type Recorder struct {
+	client  HTTPClient
+	headers map[string]bool
+
+	mu      sync.Mutex
+	encoder *json.Encoder
+	err     error
+}
+
+// NewRecorder returns a new Recorder that writes its recordings to w.
+//
+// opts: RecorderOpts is used to provide custom configuration, can be nil.
+func NewRecorder(w io.Writer, opts *RecorderOpts) *Recorder {
+	if opts == nil {
+		opts = &RecorderOpts{}
+	}
+
+	r := &Recorder{
+		client:  opts.HTTPClient,
+		headers: make(map[string]bool),
+		encoder: json.NewEncoder(w),
+	}
+
+	if r.client == nil {
+		r.client = &http.Client{}
+	}
+	for _, header := range append(alwaysRedactedHeaders, opts.RedactHeaders...) {
+		r.headers[http.CanonicalHeaderKey(header)] = true
+	}
+
+	return r
+}
+
+// Do sends the request with the underlying http client and records it together with its response.
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil {
+		var err error
+		requestBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(requestBody))
+	}
+
+	recording := Recording{
+		Time:          time.Now(),
+		URL:           req.URL.Redacted(),
+		RequestHeader: r.redact(req.Header),
+		RequestBody:   string(requestBody),
+	}
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		recording.Duration = time.Since(recording.Time)
+		recording.Error = err.Error()
+		r.write(recording)
+		return nil, err
+	}
+
+	responseBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(responseBody))
+	if err != nil {
+		return nil, err
+	}
+
+	recording.Duration = time.Since(recording.Time)
+	recording.StatusCode = res.StatusCode
+	recording.ResponseHeader = r.redact(res.Header)
+	recording.ResponseBody = string(responseBody)
+	r.write(recording)
+
+	return res, nil
+}
+
+// Err returns the first error that occurred while writing a recording, nil if there was none.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) write(recording Recording) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.encoder.Encode(recording); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *Recorder) redact(header http.Header) http.Header {
+	redactedHeader := header.Clone()
+	for k := range redactedHeader {
+		if r.headers[http.CanonicalHeaderKey(k)] {
+			redactedHeader[k] = []string{redacted}
+		}
+	}
+
+	return redactedHeader
+}
+
+// ReplayMode defines how a ReplayClient matches requests to recordings.
+type ReplayMode int
+
+const (
+	// ReplayStrict serves a recording only for a request with the same method and params.
+	// Every recording is served once, recordings of identical requests are served in recorded order.
+	ReplayStrict ReplayMode = iota
+
+	// ReplayLenient prefers recordings with the same method and params, but falls back to recordings with the same method.
+	// Recordings can be served any number of times.
+	ReplayLenient
+)
+
+// ReplayOpts can be provided to NewReplayClient() to change the configuration of the replay.
+//
+// Mode: how requests are matched to recordings, defaults to ReplayStrict.
+type ReplayOpts struct {
+	Mode ReplayMode
+}
+
+// ReplayClient is an HTTPClient that answers requests with recordings of a Recorder, without network access.
+// Requests are matched by method and params (for batch requests by the methods and params of all requests),
+// the ids of the responses are replaced with the ids of the replayed request.
+//
+//	file, _ := os.Open("traffic.jsonl")
+//	replay, err := jsonrpc.NewReplayClient(file, nil)
+//	rpcClient := jsonrpc.NewClientWithOpts(endpoint, &jsonrpc.RPCClientOpts{HTTPClient: replay})
+//
+// ReplayClient is created using the factory function NewReplayClient().
+type ReplayClient struct {
+	mode ReplayMode
+
+	mu       sync.Mutex
+	byKey    map[string][]*Recording
+	byMethod map[string][]*Recording
+}
+
+// NewReplayClient returns a new ReplayClient that serves the recordings read from r.
+//
+// opts: ReplayOpts is used to provide custom configuration, can be nil.
+func NewReplayClient(r io.Reader, opts *ReplayOpts) (*ReplayClient, error) {
+	if opts == nil {
+		opts = &ReplayOpts{}
+	}
+
+	replay := &ReplayClient{
+		mode:     opts.Mode,
+		byKey:    make(map[string][]*Recording),
+		byMethod: make(map[string][]*Recording),
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		recording := &Recording{}
+		if err := json.Unmarshal(scanner.Bytes(), recording); err != nil {
+			return nil, fmt.Errorf("recording in line %v: %w", line, err)
+		}
+
+		keys, err := parseReplayRequest([]byte(recording.RequestBody))
+		if err != nil {
+			return nil, fmt.Errorf("recording in line %v: %w", line, err)
+		}
+
+		replay.byKey[keys.key] = append(replay.byKey[keys.key], recording)
+		replay.byMethod[keys.methods] = append(replay.byMethod[keys.methods], recording)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return replay, nil
+}
+
+// Remaining returns the number of recordings that were not served yet.
+// In ReplayStrict mode it can be used to check that all recorded requests were replayed.
+func (c *ReplayClient) Remaining() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	remaining := 0
+	for _, recordings := range c.byKey {
+		remaining += len(recordings)
+	}
+	return remaining
+}
+
+// Do answers the request with the matching recording.
+func (c *ReplayClient) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keys, err := parseReplayRequest(body)
+	if err != nil {
+		return nil, err
+	}
+
+	recording := c.match(keys)
+	if recording == nil {
+		return nil, fmt.Errorf("%w for %v", ErrNoRecording, keys.methods)
+	}
+	if recording.Error != "" {
+		return nil, errors.New(recording.Error)
+	}
+
+	recorded, err := parseReplayRequest([]byte(recording.RequestBody))
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody := replaceResponseIDs([]byte(recording.ResponseBody), recorded.ids, keys.ids)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recording.StatusCode, http.StatusText(recording.StatusCode)),
+		StatusCode:    recording.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recording.ResponseHeader.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(responseBody)),
+		ContentLength: int64(len(responseBody)),
+		Request:       req,
+	}, nil
+}
+
+func (c *ReplayClient) match(keys *replayKeys) *Recording {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if recordings := c.byKey[keys.key]; len(recordings) > 0 {
+		if c.mode == ReplayLenient {
+			return recordings[0]
+		}
+		c.byKey[keys.key] = recordings[1:]
+		return recordings[0]
+	}
+
+	if c.mode == ReplayLenient {
+		if recordings := c.byMethod[keys.methods]; len(recordings) > 0 {
+			return recordings[0]
+		}
+	}
+
+	return nil
+}
+
+// replayKeys identify a single or batch request for matching.
+type replayKeys struct {
+	key     string
+	methods string
+	ids     []json.RawMessage
+}
+
+// parseReplayRequest returns the keys of an encoded single or batch request.
+func parseReplayRequest(body []byte) (*replayKeys, error) {
+	type request struct {
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+		ID     json.RawMessage `json:"id"`
+	}
+
+	var requests []request
+	body = bytes.TrimSpace(body)
+	batch := len(body) > 0 && body[0] == '['
+	if batch {
+		if err := json.Unmarshal(body, &requests); err != nil {
+			return nil, fmt.Errorf("invalid batch request: %w", err)
+		}
+	} else {
+		var single request
+		if err := json.Unmarshal(body, &single); err != nil {
+			return nil, fmt.Errorf("invalid request: %w", err)
+		}
+		requests = []request{single}
+	}
+
+	keys := make([]string, len(requests))
+	methods := make([]string, len(requests))
+	ids := make([]json.RawMessage, len(requests))
+	for i, r := range requests {
+		var params interface{}
+		if len(r.Params) > 0 {
+			params = r.Params
+		}
+		key, err := requestKey(&RPCRequest{Method: r.Method, Params: params})
+		if err != nil {
+			return nil, err
+		}
+
+		keys[i] = key
+		methods[i] = r.Method
+		ids[i] = r.ID
+	}
+
+	if batch {
+		return &replayKeys{
+			key:     "[" + strings.Join(keys, "\n") + "]",
+			methods: "[" + strings.Join(methods, ",") + "]",
+			ids:     ids,
+		}, nil
+	}
+
+	return &replayKeys{key: keys[0], methods: methods[0], ids: ids}, nil
+}
+
+// replaceResponseIDs replaces the ids of the recorded requests in the response with the ids of the replayed requests.
+// The body is returned unchanged if it is no JSON-RPC response.
+func replaceResponseIDs(body []byte, recordedIDs []json.RawMessage, ids []json.RawMessage) []byte {
+	replacements := make(map[string]json.RawMessage)
+	for i := range recordedIDs {
+		if i < len(ids) {
+			replacements[string(recordedIDs[i])] = ids[i]
+		}
+	}
+
+	replace := func(response map[string]json.RawMessage) {
+		if id, ok := replacements[string(response["id"])]; ok && len(id) > 0 {
+			response["id"] = id
+		}
+	}
+
+	var batch []map[string]json.RawMessage
+	if err := json.Unmarshal(body, &batch); err == nil {
+		for _, response := range batch {
+			replace(response)
+		}
+		if replaced, err := json.Marshal(batch); err == nil {
+			return replaced
+		}
+		return body
+	}
+
+	var single map[string]json.RawMessage
+	if err := json.Unmarshal(body, &single); err == nil && single != nil {
+		replace(single)
+		if replaced, err := json.Marshal(single); err == nil {
+			return replaced
+		}
+	}
+
+	return body
+}
@@ -0,0 +1,114 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	check := assert.New(t)
+
+	s := newTestServer(t, echo(make(chan int, 10)))
+	buf := &bytes.Buffer{}
+	recorder := NewRecorder(buf, &RecorderOpts{RedactHeaders: []string{"X-Api-Key"}})
+	rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{
+		HTTPClient:    recorder,
+		CustomHeaders: map[string]string{"Authorization": "Bearer secret", "X-Api-Key": "secret", "X-Tenant": "tenant"},
+	})
+
+	var out string
+	check.Nil(rpcClient.CallFor(context.Background(), &out, "echo", "first"))
+	check.Equal("first", out)
+	_, err := rpcClient.CallBatch(context.Background(), RPCRequests{NewRequest("echo", 1), NewRequest("echo", 2)})
+	check.Nil(err)
+	_, err = NewClientWithOpts("http://127.0.0.1:1", &RPCClientOpts{HTTPClient: recorder}).Call(context.Background(), "echo", "unreachable")
+	check.NotNil(err)
+	check.Nil(recorder.Err())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	check.Len(lines, 3)
+	check.NotContains(buf.String(), "secret")
+
+	var recording Recording
+	check.Nil(json.Unmarshal([]byte(lines[0]), &recording))
+	check.Equal(s.URL, recording.URL)
+	check.JSONEq(`{"id":0,"jsonrpc":"2.0","method":"echo","params":["first"]}`, recording.RequestBody)
+	check.JSONEq(`{"jsonrpc":"2.0","id":0,"result":"first"}`, recording.ResponseBody)
+	check.Equal(http.StatusOK, recording.StatusCode)
+	check.Equal("[REDACTED]", recording.RequestHeader.Get("Authorization"))
+	check.Equal("[REDACTED]", recording.RequestHeader.Get("X-Api-Key"))
+	check.Equal("tenant", recording.RequestHeader.Get("X-Tenant"))
+	check.Greater(recording.Duration.Nanoseconds(), int64(0))
+
+	var failed Recording
+	check.Nil(json.Unmarshal([]byte(lines[2]), &failed))
+	check.NotEmpty(failed.Error)
+	check.Zero(failed.StatusCode)
+
+	t.Run("strict replay should serve every recording once", func(t *testing.T) {
+		replay, err := NewReplayClient(strings.NewReader(buf.String()), nil)
+		check.Nil(err)
+		check.Equal(3, replay.Remaining())
+		rpcClient := NewClientWithOpts("http://replay", &RPCClientOpts{HTTPClient: replay})
+
+		res, err := rpcClient.CallRaw(context.Background(), NewRequestWithID(42, "echo", "first"))
+		check.Nil(err)
+		check.Equal("first", res.Result)
+		check.Equal(42, res.ID)
+
+		responses, err := rpcClient.CallBatchRaw(context.Background(), RPCRequests{NewRequestWithID(7, "echo", 1), NewRequestWithID(8, "echo", 2)})
+		check.Nil(err)
+		check.Equal(json.Number("1"), responses.GetByID(7).Result)
+		check.Equal(json.Number("2"), responses.GetByID(8).Result)
+
+		_, err = rpcClient.Call(context.Background(), "echo", "unreachable")
+		check.NotNil(err)
+		check.False(errors.Is(err, ErrNoRecording))
+		check.Equal(0, replay.Remaining())
+
+		_, err = rpcClient.Call(context.Background(), "echo", "first")
+		check.ErrorIs(err, ErrNoRecording)
+	})
+
+	t.Run("strict replay should not match other params", func(t *testing.T) {
+		replay, _ := NewReplayClient(strings.NewReader(buf.String()), nil)
+		rpcClient := NewClientWithOpts("http://replay", &RPCClientOpts{HTTPClient: replay})
+
+		_, err := rpcClient.Call(context.Background(), "echo", "second")
+		check.ErrorIs(err, ErrNoRecording)
+		_, err = rpcClient.CallBatch(context.Background(), RPCRequests{NewRequest("echo", 2), NewRequest("echo", 1)})
+		check.ErrorIs(err, ErrNoRecording)
+	})
+
+	t.Run("lenient replay should fall back to the method", func(t *testing.T) {
+		replay, _ := NewReplayClient(strings.NewReader(buf.String()), &ReplayOpts{Mode: ReplayLenient})
+		rpcClient := NewClientWithOpts("http://replay", &RPCClientOpts{HTTPClient: replay})
+
+		for i := 0; i < 2; i++ {
+			res, err := rpcClient.Call(context.Background(), "echo", "first")
+			check.Nil(err)
+			check.Equal("first", res.Result)
+		}
+
+		res, err := rpcClient.CallBatch(context.Background(), RPCRequests{NewRequest("echo", 5), NewRequest("echo", 6)})
+		check.Nil(err)
+		check.Len(res, 2)
+
+		_, err = rpcClient.Call(context.Background(), "unknown")
+		check.ErrorIs(err, ErrNoRecording)
+	})
+
+	t.Run("invalid recordings should return error", func(t *testing.T) {
+		_, err := NewReplayClient(strings.NewReader("{invalid"), nil)
+		check.NotNil(err)
+		_, err = NewReplayClient(strings.NewReader(`{"requestBody":"invalid"}`), nil)
+		check.NotNil(err)
+	})
+}
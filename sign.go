@@ -0,0 +1,202 @@
+package jsonrpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureInput holds the parts of a request that can be covered by an HMAC signature (see HMACSignerOpts.Canonical).
+//
+// Timestamp and Nonce: the values that are sent in the timestamp and nonce headers.
+//
+// Request: the http request with all headers set, except the signature headers.
+//
+// Body: the encoded request body.
+type SignatureInput struct {
+	Timestamp string
+	Nonce     string
+	Request   *http.Request
+	Body      []byte
+}
+
+// HMACSignerOpts can be provided to NewHMACSigner() to change the configuration of the signer.
+//
+// Hash: hash function of the HMAC, defaults to sha256.New.
+//
+// Canonical: builds the string that is signed, defaults to DefaultCanonical.
+//
+// Encode: encodes the signature, defaults to hex.EncodeToString (e.g. use base64.StdEncoding.EncodeToString).
+//
+// SignatureHeader, TimestampHeader, NonceHeader: names of the headers, default to "X-Signature", "X-Timestamp" and "X-Nonce".
+// TimestampHeader or NonceHeader can be set to "-" if the value is only part of the signature and not sent.
+//
+// KeyID and KeyIDHeader: if KeyID is set, it is sent in KeyIDHeader, defaults to "X-Key-Id".
+//
+// Timestamp: formats the time of the request, defaults to unix seconds.
+//
+// Nonce: returns a nonce that is unique for every request, defaults to RandomNonce.
+// Use MonotonicNonce() for servers that require increasing nonces.
+type HMACSignerOpts struct {
+	Hash            func() hash.Hash
+	Canonical       func(input *SignatureInput) string
+	Encode          func(signature []byte) string
+	SignatureHeader string
+	TimestampHeader string
+	NonceHeader     string
+	KeyID           string
+	KeyIDHeader     string
+	Timestamp       func(t time.Time) string
+	Nonce           func() (string, error)
+}
+
+// HMACSigner is an AuthProvider that signs every request with an HMAC over timestamp, nonce and body.
+//
+// Every request, including retries and hedges, gets a fresh timestamp and nonce, so that the server
+// can reject replayed requests.
+//
+// e.g.
+//
+//	signer := jsonrpc.NewHMACSigner([]byte("secret"), &jsonrpc.HMACSignerOpts{KeyID: "my-key"})
+//	rpcClient := jsonrpc.NewClientWithOpts(endpoint, &jsonrpc.RPCClientOpts{Auth: signer})
+//
+// Use ChainAuth() to sign requests that carry other credentials as well.
+//
+// HMACSigner is created using the factory function NewHMACSigner().
+type HMACSigner struct {
+	secret []byte
+	opts   HMACSignerOpts
+	now    func() time.Time
+}
+
+// NewHMACSigner returns a new HMACSigner.
+//
+// secret: the key of the HMAC.
+//
+// opts: HMACSignerOpts is used to provide custom configuration, can be nil.
+func NewHMACSigner(secret []byte, opts *HMACSignerOpts) *HMACSigner {
+	s := &HMACSigner{
+		secret: append([]byte(nil), secret...),
+		now:    time.Now,
+	}
+
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.Hash == nil {
+		s.opts.Hash = sha256.New
+	}
+	if s.opts.Canonical == nil {
+		s.opts.Canonical = DefaultCanonical
+	}
+	if s.opts.Encode == nil {
+		s.opts.Encode = hex.EncodeToString
+	}
+	if s.opts.SignatureHeader == "" {
+		s.opts.SignatureHeader = "X-Signature"
+	}
+	if s.opts.TimestampHeader == "" {
+		s.opts.TimestampHeader = "X-Timestamp"
+	}
+	if s.opts.NonceHeader == "" {
+		s.opts.NonceHeader = "X-Nonce"
+	}
+	if s.opts.KeyIDHeader == "" {
+		s.opts.KeyIDHeader = "X-Key-Id"
+	}
+	if s.opts.Timestamp == nil {
+		s.opts.Timestamp = func(t time.Time) string {
+			return strconv.FormatInt(t.Unix(), 10)
+		}
+	}
+	if s.opts.Nonce == nil {
+		s.opts.Nonce = RandomNonce
+	}
+
+	return s
+}
+
+// Authenticate sets the timestamp, nonce, key id and signature headers of the request.
+func (s *HMACSigner) Authenticate(ctx context.Context, request *http.Request, body []byte) error {
+	nonce, err := s.opts.Nonce()
+	if err != nil {
+		return fmt.Errorf("create nonce: %w", err)
+	}
+
+	input := &SignatureInput{
+		Timestamp: s.opts.Timestamp(s.now()),
+		Nonce:     nonce,
+		Request:   request,
+		Body:      body,
+	}
+
+	setHeader(request.Header, s.opts.TimestampHeader, input.Timestamp)
+	setHeader(request.Header, s.opts.NonceHeader, input.Nonce)
+	if s.opts.KeyID != "" {
+		setHeader(request.Header, s.opts.KeyIDHeader, s.opts.KeyID)
+	}
+
+	mac := hmac.New(s.opts.Hash, s.secret)
+	mac.Write([]byte(s.opts.Canonical(input)))
+	request.Header.Set(s.opts.SignatureHeader, s.opts.Encode(mac.Sum(nil)))
+
+	return nil
+}
+
+// setHeader sets a header, unless its name is "-".
+func setHeader(header http.Header, name string, value string) {
+	if name != "-" {
+		header.Set(name, value)
+	}
+}
+
+// DefaultCanonical is the default canonical string of HMACSigner:
+// timestamp, nonce, http method, request path and body, separated by newlines.
+func DefaultCanonical(input *SignatureInput) string {
+	return strings.Join([]string{
+		input.Timestamp,
+		input.Nonce,
+		input.Request.Method,
+		input.Request.URL.EscapedPath(),
+		string(input.Body),
+	}, "\n")
+}
+
+// RandomNonce returns 16 random bytes, hex encoded.
+func RandomNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// MonotonicNonce returns a nonce function that returns strictly increasing numbers, starting at the current unix time in microseconds.
+// The returned function is safe for concurrent use. Nonces are only increasing across restarts, as long as
+// less than one million requests per second are sent.
+func MonotonicNonce() func() (string, error) {
+	var mu sync.Mutex
+	var last int64
+
+	return func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		next := time.Now().UnixMicro()
+		if next <= last {
+			next = last + 1
+		}
+		last = next
+
+		return strconv.FormatInt(next, 10), nil
+	}
+}
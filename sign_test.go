@@ -0,0 +1,110 @@
+package jsonrpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHMACSigner(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("requests should be signed with default options", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":"ok"}`))
+		signer := NewHMACSigner([]byte("secret"), &HMACSignerOpts{KeyID: "key-1"})
+		signer.now = func() time.Time { return time.Unix(1700000000, 0) }
+		rpcClient := NewClientWithOpts(s.URL+"/rpc", &RPCClientOpts{Auth: signer})
+
+		_, err := rpcClient.Call(context.Background(), "getBlock", 1)
+		check.Nil(err)
+
+		header := <-s.headers
+		body := <-s.bodies
+		check.Equal("1700000000", header.Get("X-Timestamp"))
+		check.Equal("key-1", header.Get("X-Key-Id"))
+		check.Len(header.Get("X-Nonce"), 32)
+
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte("1700000000\n" + header.Get("X-Nonce") + "\nPOST\n/rpc\n" + body))
+		check.Equal(hex.EncodeToString(mac.Sum(nil)), header.Get("X-Signature"))
+	})
+
+	t.Run("every request should get a new nonce", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":"ok"}`))
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Auth: NewHMACSigner([]byte("secret"), nil)})
+
+		rpcClient.Call(context.Background(), "getBlock")
+		rpcClient.Call(context.Background(), "getBlock")
+		check.NotEqual((<-s.headers).Get("X-Nonce"), (<-s.headers).Get("X-Nonce"))
+	})
+
+	t.Run("canonical string, hash, encoding and headers should be configurable", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":"ok"}`))
+		signer := NewHMACSigner([]byte("secret"), &HMACSignerOpts{
+			Hash: sha512.New,
+			Canonical: func(input *SignatureInput) string {
+				return input.Nonce + input.Request.Header.Get("X-Api-Key") + string(input.Body)
+			},
+			Encode:          base64.StdEncoding.EncodeToString,
+			SignatureHeader: "Api-Sign",
+			NonceHeader:     "Api-Nonce",
+			TimestampHeader: "-",
+			Nonce:           func() (string, error) { return "42", nil },
+		})
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{
+			CustomHeaders: map[string]string{"X-Api-Key": "key"},
+			Auth:          signer,
+		})
+
+		_, err := rpcClient.Call(context.Background(), "getBlock")
+		check.Nil(err)
+
+		header := <-s.headers
+		mac := hmac.New(sha512.New, []byte("secret"))
+		mac.Write([]byte("42key" + <-s.bodies))
+		check.Equal(base64.StdEncoding.EncodeToString(mac.Sum(nil)), header.Get("Api-Sign"))
+		check.Equal("42", header.Get("Api-Nonce"))
+		check.Empty(header.Get("X-Timestamp"))
+		check.Empty(header.Get("X-Signature"))
+	})
+}
+
+func TestMonotonicNonce(t *testing.T) {
+	check := assert.New(t)
+
+	nonce := MonotonicNonce()
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				n, err := nonce()
+				check.Nil(err)
+				mu.Lock()
+				seen[n] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	check.Len(seen, 1000)
+
+	a, _ := nonce()
+	b, _ := nonce()
+	an, _ := strconv.ParseInt(a, 10, 64)
+	bn, _ := strconv.ParseInt(b, 10, 64)
+	check.Greater(bn, an)
+}
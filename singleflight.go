@@ -0,0 +1,202 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// singleflight collapses concurrent identical calls into one upstream request.
+type singleflight struct {
+	methods map[string]bool
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is an upstream request that is shared by all waiters with the same key.
+type flight struct {
+	done    chan struct{}
+	res     []byte
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func newSingleflight(methods []string) *singleflight {
+	if len(methods) == 0 {
+		return nil
+	}
+
+	sf := &singleflight{
+		methods: make(map[string]bool),
+		flights: make(map[string]*flight),
+	}
+	for _, method := range methods {
+		sf.methods[method] = true
+	}
+
+	return sf
+}
+
+func (sf *singleflight) enabled(method string) bool {
+	return sf != nil && sf.methods[method]
+}
+
+// do invokes call once for all concurrent requests with the same method, params and headers (see callHeaders())
+// and returns its result to every waiter.
+//
+// The upstream request is detached from the waiter's context and only canceled when all waiters are gone.
+// It does not carry the trace context of the waiter that started it, since it is shared by all waiters.
+func (sf *singleflight) do(ctx context.Context, headers string, request *RPCRequest, call func(ctx context.Context, request *RPCRequest) (*RPCResponse, error)) (*RPCResponse, error) {
+	key, err := requestKey(request)
+	if err != nil {
+		return call(ctx, request)
+	}
+	if headers != "" {
+		key += "\x00" + headers
+	}
+
+	sf.mu.Lock()
+	f, ok := sf.flights[key]
+	if !ok {
+		upstreamCtx, cancel := context.WithCancel(ContextWithTraceContext(context.WithoutCancel(ctx), TraceContext{}))
+		f = &flight{
+			done:   make(chan struct{}),
+			cancel: cancel,
+		}
+		sf.flights[key] = f
+
+		go func() {
+			res, err := call(upstreamCtx, request)
+			cancel()
+			// the response is encoded once and decoded by every waiter, so that waiters share no values
+			if res != nil {
+				var encodeErr error
+				if f.res, encodeErr = json.Marshal(res); encodeErr != nil && err == nil {
+					err = encodeErr
+				}
+			}
+			f.err = err
+
+			sf.mu.Lock()
+			if sf.flights[key] == f {
+				delete(sf.flights, key)
+			}
+			sf.mu.Unlock()
+
+			close(f.done)
+		}()
+	}
+	f.waiters++
+	sf.mu.Unlock()
+
+	select {
+	case <-f.done:
+		if f.res == nil {
+			return nil, f.err
+		}
+		// every waiter gets an own copy with the id of its own request
+		res, err := decodeResponse(f.res)
+		if err != nil {
+			return nil, fmt.Errorf("rpc call %v(): %w", request.Method, err)
+		}
+		res.ID = request.ID
+		return res, f.err
+	case <-ctx.Done():
+		sf.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			f.cancel()
+			if sf.flights[key] == f {
+				delete(sf.flights, key)
+			}
+		}
+		sf.mu.Unlock()
+		return nil, fmt.Errorf("rpc call %v(): %w", request.Method, ctx.Err())
+	}
+}
+
+// requestKey returns the method and the canonical json encoding of the params of the request.
+func requestKey(request *RPCRequest) (string, error) {
+	params, err := canonicalJSON(request.Params)
+	if err != nil {
+		return "", err
+	}
+
+	return request.Method + "\x00" + string(params), nil
+}
+
+// canonicalJSON encodes v as json with sorted object keys and normalized numbers, so that equal values have equal encodings.
+func canonicalJSON(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(normalizeNumbers(generic))
+}
+
+// normalizeNumbers replaces all numbers of a decoded json value by their normalized form (see normalizeNumber()).
+func normalizeNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		return normalizeNumber(v)
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalizeNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalizeNumbers(e)
+		}
+	}
+	return v
+}
+
+// normalizeNumber returns the shortest exact form of a json number, e.g. 1.0, 1e0 and 0.1e1 all become 1
+// and 0.50 becomes 5e-1. Integers with up to 16 digits are written without exponent.
+func normalizeNumber(n json.Number) json.Number {
+	digits := string(n)
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+
+	exp := 0
+	if i := strings.IndexAny(digits, "eE"); i >= 0 {
+		e, err := strconv.Atoi(digits[i+1:])
+		if err != nil {
+			return n
+		}
+		exp, digits = e, digits[:i]
+	}
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		exp -= len(digits) - i - 1
+		digits = digits[:i] + digits[i+1:]
+	}
+
+	digits = strings.TrimLeft(digits, "0")
+	if digits == "" {
+		return "0"
+	}
+	trimmed := strings.TrimRight(digits, "0")
+	exp += len(digits) - len(trimmed)
+
+	if exp >= 0 && len(trimmed)+exp <= 16 {
+		return json.Number(sign + trimmed + strings.Repeat("0", exp))
+	}
+	return json.Number(sign + trimmed + "e" + strconv.Itoa(exp))
+}
@@ -0,0 +1,256 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSingleflight(t *testing.T) {
+	check := assert.New(t)
+
+	var requests, canceled int32
+	var traceparent atomic.Value
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		traceparent.Store(r.Header.Get("traceparent"))
+		atomic.AddInt32(&requests, 1)
+		select {
+		case <-release:
+			w.Write([]byte(`{"result":"block","id":0}`))
+		case <-r.Context().Done():
+			atomic.AddInt32(&canceled, 1)
+		}
+	}))
+	defer s.Close()
+
+	client := NewClientWithOpts(s.URL, &RPCClientOpts{DeduplicateMethods: []string{"getBlock"}}).(*rpcClient)
+
+	waiters := func(request *RPCRequest) int {
+		key, _ := requestKey(request)
+		client.singleflight.mu.Lock()
+		defer client.singleflight.mu.Unlock()
+		if f, ok := client.singleflight.flights[key]; ok {
+			return f.waiters
+		}
+		return 0
+	}
+
+	t.Run("identical calls should be collapsed", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		release = make(chan struct{})
+
+		type params struct {
+			Number int  `json:"number"`
+			Full   bool `json:"full"`
+		}
+
+		var wg sync.WaitGroup
+		results := make(chan *RPCResponse, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				var res *RPCResponse
+				var err error
+				// struct and map params with the same content must be treated equal
+				if i%2 == 0 {
+					res, err = client.Call(context.Background(), "getBlock", params{Number: 1, Full: true})
+				} else {
+					res, err = client.CallRaw(context.Background(), NewRequestWithID(i, "getBlock", map[string]interface{}{"full": true, "number": 1}))
+				}
+				check.Nil(err)
+				results <- res
+			}(i)
+		}
+
+		check.Eventually(func() bool {
+			return waiters(NewRequest("getBlock", params{Number: 1, Full: true})) == 10
+		}, time.Second, time.Millisecond)
+		close(release)
+		wg.Wait()
+		close(results)
+
+		check.Equal(int32(1), atomic.LoadInt32(&requests))
+		ids := make(map[int]bool)
+		for res := range results {
+			check.Equal("block", res.Result)
+			ids[res.ID] = true
+		}
+		// every caller receives the response with its own request id
+		check.True(ids[1] && ids[3])
+	})
+
+	t.Run("waiters should not share the values of the response", func(t *testing.T) {
+		s := newTestServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+			time.Sleep(20 * time.Millisecond)
+			w.Write([]byte(`{"result":{"hash":"0x1"},"id":0}`))
+		})
+		client := NewClientWithOpts(s.URL, &RPCClientOpts{DeduplicateMethods: []string{"getBlock"}})
+
+		var wg sync.WaitGroup
+		results := make([]*RPCResponse, 2)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], _ = client.Call(context.Background(), "getBlock", 8)
+			}(i)
+		}
+		wg.Wait()
+
+		check.Equal(1, s.requests())
+		results[0].Result.(map[string]interface{})["hash"] = "changed"
+		check.Equal("0x1", results[1].Result.(map[string]interface{})["hash"])
+	})
+
+	t.Run("other methods and params should not be collapsed", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		release = make(chan struct{})
+		close(release)
+
+		client.Call(context.Background(), "getBlock", 1)
+		client.Call(context.Background(), "getBlock", 2)
+		client.Call(context.Background(), "sendTransaction", 1)
+		check.Equal(int32(3), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("calls with different headers should not be collapsed", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		release = make(chan struct{})
+
+		var wg sync.WaitGroup
+		for _, tenant := range []string{"a", "b", "a"} {
+			wg.Add(1)
+			go func(tenant string) {
+				defer wg.Done()
+				_, err := client.Call(WithHeaders(context.Background(), map[string]string{"X-Tenant": tenant}), "getBlock", 5)
+				check.Nil(err)
+			}(tenant)
+		}
+
+		check.Eventually(func() bool { return atomic.LoadInt32(&requests) == 2 }, time.Second, time.Millisecond)
+		close(release)
+		wg.Wait()
+		check.Equal(int32(2), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("calls with a meta collector should not be collapsed", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		release = make(chan struct{})
+
+		var wg sync.WaitGroup
+		metas := make([]*MetaCollector, 2)
+		for i := range metas {
+			var ctx context.Context
+			ctx, metas[i] = WithMetaCollector(context.Background())
+			wg.Add(1)
+			go func(ctx context.Context) {
+				defer wg.Done()
+				_, err := client.Call(ctx, "getBlock", 6)
+				check.Nil(err)
+			}(ctx)
+		}
+
+		check.Eventually(func() bool { return atomic.LoadInt32(&requests) == 2 }, time.Second, time.Millisecond)
+		close(release)
+		wg.Wait()
+		for _, meta := range metas {
+			check.Len(meta.All(), 1)
+		}
+	})
+
+	t.Run("shared request should not carry the trace context of a caller", func(t *testing.T) {
+		release = make(chan struct{})
+		close(release)
+
+		tc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		check.Nil(err)
+		_, err = client.Call(ContextWithTraceContext(context.Background(), tc), "getBlock", 7)
+		check.Nil(err)
+		check.Equal("", traceparent.Load())
+	})
+
+	t.Run("canceled waiters should not affect other waiters", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		release = make(chan struct{})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error, 1)
+		go func() {
+			_, err := client.Call(ctx, "getBlock", 3)
+			errs <- err
+		}()
+		check.Eventually(func() bool { return waiters(NewRequest("getBlock", 3)) == 1 }, time.Second, time.Millisecond)
+
+		results := make(chan *RPCResponse, 1)
+		go func() {
+			res, _ := client.Call(context.Background(), "getBlock", 3)
+			results <- res
+		}()
+		check.Eventually(func() bool { return waiters(NewRequest("getBlock", 3)) == 2 }, time.Second, time.Millisecond)
+
+		cancel()
+		check.ErrorIs(<-errs, context.Canceled)
+
+		close(release)
+		res := <-results
+		check.Equal("block", res.Result)
+		check.Equal(int32(1), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("upstream request should be canceled if all waiters are gone", func(t *testing.T) {
+		atomic.StoreInt32(&canceled, 0)
+		release = make(chan struct{})
+		defer close(release)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := client.Call(ctx, "getBlock", 4)
+		check.ErrorIs(err, context.DeadlineExceeded)
+
+		check.Eventually(func() bool { return atomic.LoadInt32(&canceled) == 1 }, time.Second, time.Millisecond)
+		check.Equal(0, waiters(NewRequest("getBlock", 4)))
+	})
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	check := assert.New(t)
+
+	a, err := canonicalJSON(map[string]interface{}{"b": 1.0, "a": []int{1, 2}})
+	check.Nil(err)
+	b, err := canonicalJSON(struct {
+		B int   `json:"b"`
+		A []int `json:"a"`
+	}{1, []int{1, 2}})
+	check.Nil(err)
+	check.Equal(string(a), string(b))
+	check.Equal(`{"a":[1,2],"b":1}`, string(a))
+
+	// numbers are compared by value, not by their literal
+	c, err := canonicalJSON(json.RawMessage(`{"b":1.0,"a":[0.1e1,2E0]}`))
+	check.Nil(err)
+	check.Equal(string(a), string(c))
+
+	for literal, normalized := range map[string]string{
+		"0":                    "0",
+		"-0.0":                 "0",
+		"100":                  "100",
+		"1.50":                 "15e-1",
+		"-0.005":               "-5e-3",
+		"12345678901234567890": "1234567890123456789e1",
+		"1e20":                 "1e20",
+		"1.5e300":              "15e299",
+	} {
+		check.Equal(json.Number(normalized), normalizeNumber(json.Number(literal)), literal)
+	}
+}
@@ -0,0 +1,80 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TimeoutError is returned if a call exceeded its deadline.
+//
+// Timeout: the timeout that was configured for the method (see RPCClientOpts.Timeout and MethodTimeouts), 0 if none.
+//
+// Deadline: the effective deadline of the call, which may also have been set by the caller's context.
+//
+// errors.Is(err, context.DeadlineExceeded) is true for every TimeoutError.
+type TimeoutError struct {
+	Timeout  time.Duration
+	Deadline time.Time
+	err      error
+}
+
+// Error function is provided to be used as error object.
+func (e *TimeoutError) Error() string {
+	if e.Timeout > 0 {
+		return fmt.Sprintf("%v (timeout: %v, deadline: %v)", e.err, e.Timeout, e.Deadline.Format(time.RFC3339Nano))
+	}
+	return fmt.Sprintf("%v (deadline: %v)", e.err, e.Deadline.Format(time.RFC3339Nano))
+}
+
+// Unwrap returns the underlying error.
+func (e *TimeoutError) Unwrap() error {
+	return e.err
+}
+
+// timeoutFor returns the timeout configured for the given methods.
+// For batch requests the longest timeout of all methods is used.
+func (client *rpcClient) timeoutFor(methods map[string]int) time.Duration {
+	var timeout time.Duration
+	for method := range methods {
+		t, ok := client.methodTimeouts[method]
+		if !ok {
+			t = client.timeout
+		}
+		if t > timeout {
+			timeout = t
+		}
+	}
+
+	return timeout
+}
+
+// withTimeout returns a child context with the configured timeout of the given methods applied.
+func (client *rpcClient) withTimeout(ctx context.Context, methods map[string]int) (context.Context, time.Duration, context.CancelFunc) {
+	timeout := client.timeoutFor(methods)
+	if timeout <= 0 {
+		return ctx, 0, func() {}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, timeout, cancel
+}
+
+// timeoutError wraps err in a TimeoutError if the call failed because its deadline was exceeded.
+func timeoutError(ctx context.Context, timeout time.Duration, err error) error {
+	if err == nil || !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return err
+	}
+
+	return &TimeoutError{
+		Timeout:  timeout,
+		Deadline: deadline,
+		err:      err,
+	}
+}
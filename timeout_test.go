@@ -0,0 +1,87 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeouts(t *testing.T) {
+	check := assert.New(t)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body[0]) == "[" {
+			w.Write([]byte(`[{"result":"slow","id":0}]`))
+			return
+		}
+		select {
+		case <-time.After(100 * time.Millisecond):
+			w.Write([]byte(`{"result":"slow"}`))
+		case <-r.Context().Done():
+		}
+	}))
+	defer s.Close()
+
+	client := NewClientWithOpts(s.URL, &RPCClientOpts{
+		Timeout: 20 * time.Millisecond,
+		MethodTimeouts: map[string]time.Duration{
+			"debug_traceTransaction": time.Second,
+		},
+	})
+
+	t.Run("default timeout should be applied", func(t *testing.T) {
+		start := time.Now()
+		_, err := client.Call(context.Background(), "getBlock")
+		check.Less(time.Since(start), 100*time.Millisecond)
+		check.True(errors.Is(err, context.DeadlineExceeded))
+
+		var timeoutErr *TimeoutError
+		check.True(errors.As(err, &timeoutErr))
+		check.Equal(20*time.Millisecond, timeoutErr.Timeout)
+		check.False(timeoutErr.Deadline.IsZero())
+		check.Contains(err.Error(), "timeout: 20ms")
+	})
+
+	t.Run("method timeout should override default timeout", func(t *testing.T) {
+		res, err := client.Call(context.Background(), "debug_traceTransaction")
+		check.Nil(err)
+		check.Equal("slow", res.Result)
+	})
+
+	t.Run("shorter caller deadline should be respected", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		deadline, _ := ctx.Deadline()
+
+		_, err := client.Call(ctx, "debug_traceTransaction")
+		var timeoutErr *TimeoutError
+		check.True(errors.As(err, &timeoutErr))
+		check.Equal(deadline, timeoutErr.Deadline)
+		check.Equal(time.Second, timeoutErr.Timeout)
+	})
+
+	t.Run("batch should use the longest timeout", func(t *testing.T) {
+		units := batchUnits(RPCRequests{NewRequest("getBlock"), NewRequest("debug_traceTransaction")})
+		check.Equal(time.Second, client.(*rpcClient).timeoutFor(units))
+
+		_, err := client.CallBatch(context.Background(), RPCRequests{NewRequest("getBlock")})
+		check.Nil(err)
+	})
+
+	t.Run("errors without deadline should not be wrapped", func(t *testing.T) {
+		client := NewClient(s.URL)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := client.Call(ctx, "getBlock")
+		var timeoutErr *TimeoutError
+		check.False(errors.As(err, &timeoutErr))
+		check.True(errors.Is(err, context.Canceled))
+	})
+}
@@ -0,0 +1,244 @@
+package jsonrpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+)
+
+// TraceContext is a W3C Trace Context (see https://www.w3.org/TR/trace-context/).
+//
+// TraceID: id of the whole trace.
+//
+// SpanID: id of the current span, the parent of spans started by the receiver.
+//
+// Flags: trace flags, bit 0 is the sampled flag.
+//
+// State: vendor specific trace state, sent unchanged in the tracestate header.
+type TraceContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+	State   string
+}
+
+// IsValid returns true if trace id and span id are not zero.
+func (tc TraceContext) IsValid() bool {
+	return tc.TraceID != [16]byte{} && tc.SpanID != [8]byte{}
+}
+
+// Sampled returns true if the sampled flag is set.
+func (tc TraceContext) Sampled() bool {
+	return tc.Flags&0x01 != 0
+}
+
+// Traceparent returns the value of the traceparent header, e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func (tc TraceContext) Traceparent() string {
+	return fmt.Sprintf("00-%x-%x-%02x", tc.TraceID, tc.SpanID, tc.Flags)
+}
+
+// NewChild returns a trace context with a new random span id in the same trace.
+//
+// If tc is not valid, a new trace is started with the sampled flag set.
+func (tc TraceContext) NewChild() TraceContext {
+	child := tc
+	if !tc.IsValid() {
+		child = TraceContext{Flags: 0x01}
+		for child.TraceID == [16]byte{} {
+			rand.Read(child.TraceID[:])
+		}
+	}
+
+	child.SpanID = [8]byte{}
+	for child.SpanID == [8]byte{} {
+		rand.Read(child.SpanID[:])
+	}
+
+	return child
+}
+
+// ParseTraceparent parses the value of a traceparent header.
+func ParseTraceparent(traceparent string) (TraceContext, error) {
+	var tc TraceContext
+
+	s := strings.TrimSpace(traceparent)
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return tc, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+
+	version, err := decodeLowerHex(s[0:2])
+	if err != nil || version[0] == 0xff {
+		return tc, fmt.Errorf("invalid traceparent version %q", s[0:2])
+	}
+	// version 00 has a fixed length, future versions may append fields
+	if (version[0] == 0 && len(s) != 55) || (len(s) > 55 && s[55] != '-') {
+		return tc, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+
+	traceID, err := decodeLowerHex(s[3:35])
+	if err != nil {
+		return tc, fmt.Errorf("invalid trace id %q", s[3:35])
+	}
+	spanID, err := decodeLowerHex(s[36:52])
+	if err != nil {
+		return tc, fmt.Errorf("invalid span id %q", s[36:52])
+	}
+	flags, err := decodeLowerHex(s[53:55])
+	if err != nil {
+		return tc, fmt.Errorf("invalid trace flags %q", s[53:55])
+	}
+
+	copy(tc.TraceID[:], traceID)
+	copy(tc.SpanID[:], spanID)
+	tc.Flags = flags[0]
+
+	if !tc.IsValid() {
+		return TraceContext{}, errors.New("invalid traceparent: trace id and span id must not be zero")
+	}
+
+	return tc, nil
+}
+
+func decodeLowerHex(s string) ([]byte, error) {
+	if strings.ToLower(s) != s {
+		return nil, errors.New("hex must be lowercase")
+	}
+	return hex.DecodeString(s)
+}
+
+type traceContextKey struct{}
+
+// ContextWithTraceContext returns a copy of ctx that carries tc.
+// The client sends it in the traceparent and tracestate headers of every request made with the context.
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceContextFromContext returns the trace context carried by ctx.
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok && tc.IsValid()
+}
+
+// ExtractTraceContext returns a copy of ctx that carries the trace context of the traceparent and tracestate headers.
+// ctx is returned unchanged if the headers do not contain a valid trace context.
+//
+// It can be used on the server side to continue the trace of the client:
+//
+//	func handler(w http.ResponseWriter, r *http.Request) {
+//		ctx := jsonrpc.ExtractTraceContext(r.Context(), r.Header)
+//		...
+//	}
+func ExtractTraceContext(ctx context.Context, header http.Header) context.Context {
+	tc, err := ParseTraceparent(header.Get(traceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	tc.State = strings.Join(header.Values(tracestateHeader), ",")
+
+	return ContextWithTraceContext(ctx, tc)
+}
+
+// injectTraceContext sets the traceparent and tracestate headers from the trace context carried by ctx.
+func injectTraceContext(ctx context.Context, header http.Header) {
+	tc, ok := TraceContextFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	header.Set(traceparentHeader, tc.Traceparent())
+	if tc.State != "" {
+		header.Set(tracestateHeader, tc.State)
+	}
+}
+
+// Tracer starts a span for every call and batch call of an RPCClient (see RPCClientOpts.Tracer).
+//
+// Start must return a context that carries the trace context of the new span (see ContextWithTraceContext()),
+// so that it is propagated to the server.
+//
+// Implementations can be adapters to tracing libraries like OpenTelemetry.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+//
+// The client sets the following attributes:
+//
+//	rpc.system                  "jsonrpc"
+//	rpc.method                  method of the request (single calls)
+//	rpc.jsonrpc.request_id      id of the request (single calls)
+//	rpc.jsonrpc.batch_size      number of requests (batch calls)
+//	http.response.status_code   http status code, if a response was received
+//	rpc.jsonrpc.error_code      code of the (first) RPCError, if there was one
+type Span interface {
+	SetAttribute(key string, value interface{})
+
+	// End ends the span, err is the error returned by the call, nil if it succeeded.
+	End(err error)
+}
+
+// startSpan starts a span for a call, span is nil if no tracer is configured.
+func (client *rpcClient) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	if client.tracer == nil {
+		return ctx, nil
+	}
+
+	ctx, span := client.tracer.Start(ctx, name)
+	span.SetAttribute("rpc.system", "jsonrpc")
+
+	return ctx, span
+}
+
+func endCallSpan(span Span, request *RPCRequest, response *RPCResponse, ex *exchange, err error) {
+	if span == nil {
+		return
+	}
+
+	span.SetAttribute("rpc.method", request.Method)
+	span.SetAttribute("rpc.jsonrpc.request_id", request.ID)
+
+	var rpcErr *RPCError
+	if response != nil {
+		rpcErr = response.Error
+	}
+	endSpan(span, rpcErr, ex, err)
+}
+
+func endBatchSpan(span Span, requests []*RPCRequest, responses []*RPCResponse, ex *exchange, err error) {
+	if span == nil {
+		return
+	}
+
+	span.SetAttribute("rpc.jsonrpc.batch_size", len(requests))
+
+	var rpcErr *RPCError
+	for _, response := range responses {
+		if response != nil && response.Error != nil {
+			rpcErr = response.Error
+			break
+		}
+	}
+	endSpan(span, rpcErr, ex, err)
+}
+
+func endSpan(span Span, rpcErr *RPCError, ex *exchange, err error) {
+	if status := ex.statusCode(); status != 0 {
+		span.SetAttribute("http.response.status_code", status)
+	}
+	if rpcErr != nil {
+		span.SetAttribute("rpc.jsonrpc.error_code", rpcErr.Code)
+	}
+
+	span.End(err)
+}
@@ -0,0 +1,172 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingTracer starts child spans of the context's trace context and records them
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+type recordingSpan struct {
+	name       string
+	tc         TraceContext
+	attributes map[string]interface{}
+	ended      bool
+	err        error
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := TraceContextFromContext(ctx)
+	span := &recordingSpan{name: name, tc: parent.NewChild(), attributes: make(map[string]interface{})}
+
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+
+	return ContextWithTraceContext(ctx, span.tc), span
+}
+
+func (s *recordingSpan) SetAttribute(key string, value interface{}) {
+	s.attributes[key] = value
+}
+
+func (s *recordingSpan) End(err error) {
+	s.ended = true
+	s.err = err
+}
+
+func TestTraceContext(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("traceparent should be parsed and formatted", func(t *testing.T) {
+		tc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		check.Nil(err)
+		check.True(tc.IsValid())
+		check.True(tc.Sampled())
+		check.Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", tc.Traceparent())
+
+		// future versions may append fields
+		_, err = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+		check.Nil(err)
+	})
+
+	t.Run("invalid traceparent should return error", func(t *testing.T) {
+		for _, traceparent := range []string{
+			"",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+			"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bx-01",
+		} {
+			_, err := ParseTraceparent(traceparent)
+			check.NotNil(err, traceparent)
+		}
+	})
+
+	t.Run("child should keep the trace", func(t *testing.T) {
+		root := TraceContext{}.NewChild()
+		check.True(root.IsValid())
+		check.True(root.Sampled())
+
+		child := root.NewChild()
+		check.Equal(root.TraceID, child.TraceID)
+		check.NotEqual(root.SpanID, child.SpanID)
+	})
+
+	t.Run("server should extract the trace context", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		header.Add("tracestate", "a=1")
+		header.Add("tracestate", "b=2")
+
+		tc, ok := TraceContextFromContext(ExtractTraceContext(context.Background(), header))
+		check.True(ok)
+		check.Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", tc.Traceparent())
+		check.Equal("a=1,b=2", tc.State)
+
+		_, ok = TraceContextFromContext(ExtractTraceContext(context.Background(), http.Header{}))
+		check.False(ok)
+	})
+}
+
+func TestTracer(t *testing.T) {
+	check := assert.New(t)
+
+	t.Run("trace context of the context should be sent without tracer", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"result":"ok"}`))
+		tc, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		tc.State = "vendor=value"
+
+		NewClient(s.URL).Call(ContextWithTraceContext(context.Background(), tc), "getBlock")
+		headers := <-s.headers
+		check.Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", headers.Get("traceparent"))
+		check.Equal("vendor=value", headers.Get("tracestate"))
+
+		NewClient(s.URL).Call(context.Background(), "getBlock")
+		headers = <-s.headers
+		check.Empty(headers.Get("traceparent"))
+	})
+
+	t.Run("calls should be traced", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `{"error":{"code":-32000,"message":"failed"},"id":7}`))
+		tracer := &recordingTracer{}
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Tracer: tracer})
+
+		parent := TraceContext{}.NewChild()
+		rpcClient.CallRaw(ContextWithTraceContext(context.Background(), parent), NewRequestWithID(7, "getBlock"))
+
+		check.Len(tracer.spans, 1)
+		span := tracer.spans[0]
+		check.Equal("getBlock", span.name)
+		check.True(span.ended)
+		check.Nil(span.err)
+		check.Equal(parent.TraceID, span.tc.TraceID)
+		check.Equal("jsonrpc", span.attributes["rpc.system"])
+		check.Equal("getBlock", span.attributes["rpc.method"])
+		check.Equal(7, span.attributes["rpc.jsonrpc.request_id"])
+		check.Equal(200, span.attributes["http.response.status_code"])
+		check.Equal(-32000, span.attributes["rpc.jsonrpc.error_code"])
+
+		// the span of the call is the parent on the server side
+		headers := <-s.headers
+		check.Equal(span.tc.Traceparent(), headers.Get("traceparent"))
+	})
+
+	t.Run("batch calls should be traced", func(t *testing.T) {
+		s := newTestServer(t, respond(http.StatusOK, `[{"result":"ok","id":0},{"result":"ok","id":1}]`))
+		tracer := &recordingTracer{}
+		rpcClient := NewClientWithOpts(s.URL, &RPCClientOpts{Tracer: tracer})
+
+		_, err := rpcClient.CallBatch(context.Background(), RPCRequests{NewRequest("a"), NewRequest("b")})
+		check.Nil(err)
+
+		check.Len(tracer.spans, 1)
+		span := tracer.spans[0]
+		check.Equal("batch", span.name)
+		check.True(span.ended)
+		check.Equal(2, span.attributes["rpc.jsonrpc.batch_size"])
+		check.Nil(span.attributes["rpc.jsonrpc.error_code"])
+		check.Equal(span.tc.Traceparent(), (<-s.headers).Get("traceparent"))
+	})
+
+	t.Run("failed calls should end the span with the error", func(t *testing.T) {
+		tracer := &recordingTracer{}
+		rpcClient := NewClientWithOpts("http://127.0.0.1:1", &RPCClientOpts{Tracer: tracer})
+
+		_, err := rpcClient.Call(context.Background(), "getBlock")
+		check.NotNil(err)
+		check.Equal(err, tracer.spans[0].err)
+		check.Nil(tracer.spans[0].attributes["http.response.status_code"])
+	})
+}